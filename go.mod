@@ -1,16 +1,25 @@
 module github.com/projectriff/cnab-k8s-installer-base
 
 require (
+	github.com/BurntSushi/toml v0.3.1 // indirect
+	github.com/Masterminds/goutils v1.1.0 // indirect
+	github.com/Masterminds/semver v1.4.2 // indirect
+	github.com/Masterminds/sprig v2.20.0+incompatible // indirect
+	github.com/cyphar/filepath-securejoin v0.2.2 // indirect
 	github.com/ghodss/yaml v1.0.0
+	github.com/gobwas/glob v0.2.3 // indirect
 	github.com/golang/protobuf v1.3.1 // indirect
 	github.com/googleapis/gnostic v0.2.0 // indirect
 	github.com/gophercloud/gophercloud v0.2.0 // indirect
+	github.com/huandu/xstrings v1.2.0 // indirect
 	github.com/imdario/mergo v0.3.7 // indirect
 	github.com/onsi/ginkgo v1.8.0
 	github.com/onsi/gomega v1.5.0
 	github.com/pivotal/go-ape v0.0.0-20190410083726-7e1e93138a02
 	github.com/pkg/errors v0.8.1
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/sirupsen/logrus v1.4.2
+	github.com/spf13/cobra v0.0.4
 	github.com/stretchr/testify v1.3.0
 	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4 // indirect
 	gopkg.in/inf.v0 v0.9.1 // indirect
@@ -19,6 +28,7 @@ require (
 	k8s.io/apimachinery v0.0.0-20190820100751-ac02f8882ef6
 	k8s.io/client-go v0.0.0-20190820101407-c8dc69f8a8bf
 	k8s.io/code-generator v0.0.0-20190820100531-4a676795660b
+	k8s.io/helm v2.14.3+incompatible
 	k8s.io/kube-openapi v0.0.0-20190709113604-33be087ad058
 	sigs.k8s.io/kustomize v2.0.3+incompatible
 )
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"fmt"
+)
+
+// The phases in which the resources with a Hook are applied
+const (
+	HookPreInstall    = "preInstall"
+	HookPostInstall   = "postInstall"
+	HookPreUpgrade    = "preUpgrade"
+	HookPostUpgrade   = "postUpgrade"
+	HookPreUninstall  = "preUninstall"
+	HookPostUninstall = "postUninstall"
+)
+
+// The HookDeletePolicy of a resource, deciding whether its objects are deleted once the hook ran
+const (
+	// HookDeleteSucceeded deletes the objects when the hook succeeded, keeping them to investigate a failure
+	HookDeleteSucceeded = "succeeded"
+	// HookDeleteAlways deletes the objects whether the hook succeeded or not
+	HookDeleteAlways = "always"
+	// HookDeleteNever keeps the objects until the next run of the hook or the uninstall
+	HookDeleteNever = "never"
+)
+
+var hookPhases = []string{HookPreInstall, HookPostInstall, HookPreUpgrade, HookPostUpgrade, HookPreUninstall, HookPostUninstall}
+
+// DeletePolicy returns the HookDeletePolicy of the resource, HookDeleteSucceeded by default
+func (res KabResource) DeletePolicy() string {
+	if res.HookDeletePolicy == "" {
+		return HookDeleteSucceeded
+	}
+	return res.HookDeletePolicy
+}
+
+func checkHook(resource KabResource) error {
+	if resource.Test && (resource.Hook != "" || resource.Deferred) {
+		return fmt.Errorf("resource %s: a test cannot be a hook or be deferred", resource.Name)
+	}
+	if resource.Hook == "" {
+		if resource.HookDeletePolicy != "" {
+			return fmt.Errorf("resource %s: hookDeletePolicy is only supported for hooks", resource.Name)
+		}
+		return nil
+	}
+	valid := false
+	for _, phase := range hookPhases {
+		valid = valid || resource.Hook == phase
+	}
+	if !valid {
+		return fmt.Errorf("resource %s: unknown hook %s, supported hooks are %v", resource.Name, resource.Hook, hookPhases)
+	}
+	if resource.Deferred {
+		return fmt.Errorf("resource %s: a hook cannot be deferred", resource.Name)
+	}
+	switch resource.HookDeletePolicy {
+	case "", HookDeleteSucceeded, HookDeleteAlways, HookDeleteNever:
+		return nil
+	}
+	return fmt.Errorf("resource %s: unknown hookDeletePolicy %s, supported policies are %s, %s and %s", resource.Name, resource.HookDeletePolicy, HookDeleteSucceeded, HookDeleteAlways, HookDeleteNever)
+}
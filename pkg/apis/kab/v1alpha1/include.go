@@ -0,0 +1,142 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pivotal/go-ape/pkg/furl"
+)
+
+// manifestReader reads the manifest file at a relative path or URL
+type manifestReader func(path string) ([]byte, error)
+
+func readManifestFile(path string) ([]byte, error) {
+	yamlFile, err := furl.Read(path, "")
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest file: %v", err)
+	}
+	return yamlFile, nil
+}
+
+// resolveIncludes replaces the spec of the manifest with its spec merged over the specs of the
+// included manifests, which are themselves resolved first. including holds the paths of the
+// manifests being resolved, to detect cycles.
+func (m *Manifest) resolveIncludes(read manifestReader, including []string) error {
+	merged := KabSpec{}
+	for _, path := range m.Spec.Includes {
+		for _, p := range including {
+			if p == path {
+				return fmt.Errorf("manifest %s includes itself: %s", path, strings.Join(append(including, path), " -> "))
+			}
+		}
+		yamlFile, err := read(path)
+		if err != nil {
+			return fmt.Errorf("error including %s: %v", path, err)
+		}
+		included, err := unmarshalManifest(yamlFile)
+		if err != nil {
+			return fmt.Errorf("error including %s: %v", path, err)
+		}
+		err = included.resolveIncludes(read, append(including, path))
+		if err != nil {
+			return err
+		}
+		merged, err = overlaySpec(merged, included.Spec)
+		if err != nil {
+			return fmt.Errorf("error including %s: %v", path, err)
+		}
+	}
+	spec, err := overlaySpec(merged, m.Spec)
+	if err != nil {
+		return err
+	}
+	m.Spec = spec
+	return nil
+}
+
+// overlaySpec merges overlay over base. The resources, outputs and namespaces of overlay replace
+// the ones of base with the same name and the others are appended, a resource with Remove removes
+// the resource of base with its name. The version and requirements of overlay win when they are set.
+func overlaySpec(base KabSpec, overlay KabSpec) (KabSpec, error) {
+	result := KabSpec{
+		MinKubernetesVersion: base.MinKubernetesVersion,
+		Requirements:         base.Requirements,
+	}
+	result.Resources = append(result.Resources, base.Resources...)
+	result.Outputs = append(result.Outputs, base.Outputs...)
+	result.Namespaces = append(result.Namespaces, base.Namespaces...)
+	if overlay.MinKubernetesVersion != "" {
+		result.MinKubernetesVersion = overlay.MinKubernetesVersion
+	}
+	if overlay.Requirements != nil {
+		result.Requirements = overlay.Requirements
+	}
+
+	// only the resources of base are replaced, so that resources sharing a name in overlay are kept
+	fromBase := len(result.Resources)
+	for _, resource := range overlay.Resources {
+		i := -1
+		for j := 0; j < fromBase && resource.Name != ""; j++ {
+			if result.Resources[j].Name == resource.Name {
+				i = j
+				break
+			}
+		}
+		switch {
+		case resource.Remove && i < 0:
+			return KabSpec{}, fmt.Errorf("resource %s cannot be removed: it is not included", resource.Name)
+		case resource.Remove:
+			result.Resources = append(result.Resources[:i], result.Resources[i+1:]...)
+			fromBase--
+		case i >= 0:
+			result.Resources[i] = resource
+		default:
+			result.Resources = append(result.Resources, resource)
+		}
+	}
+
+	for _, output := range overlay.Outputs {
+		replaced := false
+		for j := range result.Outputs {
+			if result.Outputs[j].Name == output.Name {
+				result.Outputs[j] = output
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			result.Outputs = append(result.Outputs, output)
+		}
+	}
+
+	for _, namespace := range overlay.Namespaces {
+		replaced := false
+		for j := range result.Namespaces {
+			if result.Namespaces[j].Name == namespace.Name {
+				result.Namespaces[j] = namespace
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			result.Namespaces = append(result.Namespaces, namespace)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pivotal/go-ape/pkg/furl"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/oci"
+)
+
+const unsupportedPathMessage = "resources must use a http, https, oci, configmap or secret URL or a relative path"
+
+// Resolver reads the content at a resource path with the scheme the resolver is registered for
+type Resolver func(path string) ([]byte, error)
+
+// DefaultResolvers read the http, https and oci paths, which do not need access to the target cluster
+func DefaultResolvers() map[string]Resolver {
+	return map[string]Resolver{
+		"http":  readURL,
+		"https": readURL,
+		"oci":   oci.Pull,
+	}
+}
+
+// ParseClusterPath reads the namespace, name and key of a configmap://namespace/name/key or
+// secret://namespace/name/key path
+func ParseClusterPath(path string) (namespace string, name string, key string, err error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", "", "", err
+	}
+	err = checkClusterPath(u)
+	if err != nil {
+		return "", "", "", err
+	}
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	return u.Host, parts[0], parts[1], nil
+}
+
+func checkClusterPath(u *url.URL) error {
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	if u.Host == "" || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("invalid path %s, expected %s://namespace/name/key", u.String(), u.Scheme)
+	}
+	return nil
+}
+
+// readPath reads relative paths from the working directory, and the other paths with the resolver
+// for their scheme
+func readPath(path string, resolvers map[string]Resolver) ([]byte, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" {
+		return furl.Read(path, "")
+	}
+	resolve, ok := resolvers[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("cannot read %s: %s paths are only read when installing to a cluster", path, u.Scheme)
+	}
+	return resolve(path)
+}
+
+func readURL(path string) ([]byte, error) {
+	return furl.Read(path, "")
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pivotal/go-ape/pkg/furl"
+)
+
+// SignatureSuffix is appended to the path of a manifest to find its detached signature
+const SignatureSuffix = ".sig"
+
+// ParsePublicKey reads a PEM encoded PKIX public key. RSA, ECDSA and Ed25519 keys are supported.
+func ParsePublicKey(pemBytes []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("public key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing public key: %v", err)
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// VerifySignature checks the base64 encoded detached signature of content. RSA signatures are
+// PKCS #1 v1.5 and ECDSA signatures ASN.1 encoded, both over the sha256 of the content.
+func VerifySignature(key crypto.PublicKey, content []byte, signature []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("signature is not base64 encoded: %v", err)
+	}
+	digest := sha256.Sum256(content)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, digest[:], sig) {
+			err = errors.New("verification error")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, content, sig) {
+			err = errors.New("verification error")
+		}
+	default:
+		err = fmt.Errorf("unsupported public key type %T", key)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	return nil
+}
+
+// NewSignedManifest reads the manifest like NewManifest, after verifying it and every included
+// manifest file against the detached signature next to it, at its path with the SignatureSuffix.
+// Every resource read from a path must be pinned with a sha256 or a signature, so that the
+// signature covers the content installed.
+func NewSignedManifest(path string, key crypto.PublicKey) (*Manifest, error) {
+	read := func(path string) ([]byte, error) {
+		yamlFile, err := readManifestFile(path)
+		if err != nil {
+			return nil, err
+		}
+		signature, err := furl.Read(path+SignatureSuffix, "")
+		if err != nil {
+			return nil, fmt.Errorf("error reading signature of manifest file: %v", err)
+		}
+		err = VerifySignature(key, yamlFile, signature)
+		if err != nil {
+			return nil, fmt.Errorf("manifest file %s: %v", path, err)
+		}
+		return yamlFile, nil
+	}
+	yamlFile, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := parseManifest(yamlFile, read)
+	if err != nil {
+		return nil, err
+	}
+	err = manifest.VisitResources(checkResourcePinned)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// checkResourcePinned requires the content read from a path to be pinned. Charts and kustomizations
+// are always read from the bundle, which ships with the signed manifest.
+func checkResourcePinned(resource KabResource) error {
+	if resource.Chart != nil || resource.Kustomize != "" {
+		return nil
+	}
+	if resource.Content == "" && resource.Sha256 == "" && resource.Signature == "" {
+		return fmt.Errorf("resource %s at %s must have a sha256 or a signature in a signed manifest", resource.Name, resource.Path)
+	}
+	return nil
+}
+
+// InlineSignedContent embeds the content at the Path of every resource with a Signature, after
+// verifying it. The other resources are left to InlineContent.
+func (m *Manifest) InlineSignedContent(key crypto.PublicKey) error {
+	return m.PatchResourceContent(func(res *KabResource) (string, error) {
+		if res.Signature == "" || res.Content != "" {
+			return res.Content, nil
+		}
+		content, err := readPath(res.Path, DefaultResolvers())
+		if err != nil {
+			return "", err
+		}
+		signature, err := readPath(res.Signature, DefaultResolvers())
+		if err != nil {
+			return "", fmt.Errorf("error reading signature of resource %s: %v", res.Name, err)
+		}
+		err = VerifySignature(key, content, signature)
+		if err != nil {
+			return "", fmt.Errorf("resource %s at %s: %v", res.Name, res.Path, err)
+		}
+		err = res.verifyDigest(content)
+		if err != nil {
+			return "", err
+		}
+		return string(content), nil
+	})
+}
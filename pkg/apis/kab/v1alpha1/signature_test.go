@@ -0,0 +1,248 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+)
+
+func sign(key crypto.Signer, content []byte) []byte {
+	var sig []byte
+	var err error
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		sig, err = key.Sign(rand.Reader, content, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(content)
+		sig, err = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	Expect(err).NotTo(HaveOccurred())
+	return []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+}
+
+func publicKeyPEM(key crypto.Signer) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	Expect(err).NotTo(HaveOccurred())
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+var _ = Describe("Signatures", func() {
+
+	var (
+		signer crypto.Signer
+		key    crypto.PublicKey
+		err    error
+	)
+
+	BeforeEach(func() {
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		key, err = v1alpha1.ParsePublicKey(publicKeyPEM(signer))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("VerifySignature", func() {
+		content := []byte("kind: Namespace\n")
+
+		It("verifies RSA, ECDSA and Ed25519 signatures", func() {
+			rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).NotTo(HaveOccurred())
+			ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, s := range []crypto.Signer{signer, rsaKey, ecdsaKey} {
+				publicKey, err := v1alpha1.ParsePublicKey(publicKeyPEM(s))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(v1alpha1.VerifySignature(publicKey, content, sign(s, content))).To(Succeed())
+			}
+		})
+
+		It("rejects tampered content", func() {
+			err = v1alpha1.VerifySignature(key, []byte("kind: Secret\n"), sign(signer, content))
+			Expect(err).To(MatchError(HavePrefix("invalid signature: ")))
+		})
+
+		It("rejects a signature of another key", func() {
+			_, other, err := ed25519.GenerateKey(rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			err = v1alpha1.VerifySignature(key, content, sign(other, content))
+			Expect(err).To(MatchError(HavePrefix("invalid signature: ")))
+		})
+
+		It("rejects a key which is not PEM encoded", func() {
+			_, err = v1alpha1.ParsePublicKey([]byte("not a key"))
+			Expect(err).To(MatchError("public key is not PEM encoded"))
+		})
+	})
+
+	Describe("NewSignedManifest", func() {
+
+		var (
+			dir          string
+			manifestPath string
+			server       *httptest.Server
+			resource     []byte
+			manifest     *v1alpha1.Manifest
+		)
+
+		writeManifest := func(content string, signature []byte) {
+			Expect(ioutil.WriteFile(manifestPath, []byte(content), 0644)).To(Succeed())
+			if signature != nil {
+				Expect(ioutil.WriteFile(manifestPath+v1alpha1.SignatureSuffix, signature, 0644)).To(Succeed())
+			}
+		}
+
+		BeforeEach(func() {
+			dir, err = ioutil.TempDir("", "signed-manifest")
+			Expect(err).NotTo(HaveOccurred())
+			manifestPath = filepath.Join(dir, "manifest.yaml")
+
+			resource = []byte("kind: Namespace\n")
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/ns.yaml":
+					w.Write(resource)
+				case "/ns.yaml.sig":
+					w.Write(sign(signer, []byte("kind: Namespace\n")))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+			os.RemoveAll(dir)
+		})
+
+		signedManifest := func(resources string) string {
+			return "apiVersion: projectriff.io/v1alpha1\nkind: Manifest\nmetadata:\n  name: riff-install\nspec:\n  resources:\n" + resources
+		}
+
+		Context("when the manifest and resources are signed", func() {
+			BeforeEach(func() {
+				content := signedManifest("  - name: ns\n    path: " + server.URL + "/ns.yaml\n    signature: " + server.URL + "/ns.yaml.sig\n  - name: inline\n    content: 'kind: ConfigMap'\n")
+				writeManifest(content, sign(signer, []byte(content)))
+			})
+
+			It("inlines the verified content", func() {
+				manifest, err = v1alpha1.NewSignedManifest(manifestPath, key)
+				Expect(err).NotTo(HaveOccurred())
+				err = manifest.InlineSignedContent(key)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest.Spec.Resources[0].Content).To(Equal("kind: Namespace\n"))
+				Expect(manifest.Spec.Resources[1].Content).To(Equal("kind: ConfigMap"))
+			})
+
+			It("fails when a resource was tampered with", func() {
+				resource = []byte("kind: Secret\n")
+				manifest, err = v1alpha1.NewSignedManifest(manifestPath, key)
+				Expect(err).NotTo(HaveOccurred())
+				err = manifest.InlineSignedContent(key)
+				Expect(err).To(MatchError(HavePrefix("resource ns at " + server.URL + "/ns.yaml: invalid signature: ")))
+			})
+		})
+
+		Context("when the manifest was tampered with", func() {
+			It("an error is returned", func() {
+				content := signedManifest("  - name: inline\n    content: 'kind: ConfigMap'\n")
+				writeManifest(content+"  - name: extra\n    content: 'kind: Secret'\n", sign(signer, []byte(content)))
+				_, err = v1alpha1.NewSignedManifest(manifestPath, key)
+				Expect(err).To(MatchError(HavePrefix("manifest file " + manifestPath + ": invalid signature: ")))
+			})
+		})
+
+		Context("when the manifest is not signed", func() {
+			It("an error is returned", func() {
+				writeManifest(signedManifest("  - name: inline\n    content: 'kind: ConfigMap'\n"), nil)
+				_, err = v1alpha1.NewSignedManifest(manifestPath, key)
+				Expect(err).To(MatchError(HavePrefix("error reading signature of manifest file: ")))
+			})
+		})
+
+		Context("when the manifest includes a manifest file", func() {
+			var includedPath string
+
+			BeforeEach(func() {
+				includedPath = filepath.Join(dir, "base.yaml")
+				content := "apiVersion: projectriff.io/v1alpha1\nkind: Manifest\nspec:\n  includes:\n  - " + includedPath + "\n"
+				writeManifest(content, sign(signer, []byte(content)))
+				Expect(ioutil.WriteFile(includedPath, []byte(signedManifest("  - name: inline\n    content: 'kind: ConfigMap'\n")), 0644)).To(Succeed())
+			})
+
+			It("the included manifest file must be signed", func() {
+				_, err = v1alpha1.NewSignedManifest(manifestPath, key)
+				Expect(err).To(MatchError(HavePrefix("error including " + includedPath + ": error reading signature of manifest file: ")))
+			})
+
+			It("the signed included manifest file is merged", func() {
+				included, err := ioutil.ReadFile(includedPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ioutil.WriteFile(includedPath+v1alpha1.SignatureSuffix, sign(signer, included), 0644)).To(Succeed())
+				manifest, err = v1alpha1.NewSignedManifest(manifestPath, key)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest.Spec.Resources).To(HaveLen(1))
+			})
+		})
+
+		Context("when a resource is a chart", func() {
+			It("the chart in the bundle is accepted", func() {
+				content := signedManifest("  - name: riff\n    chart:\n      path: ./charts/riff\n")
+				writeManifest(content, sign(signer, []byte(content)))
+				manifest, err = v1alpha1.NewSignedManifest(manifestPath, key)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest.Spec.Resources[0].Chart.Path).To(Equal("./charts/riff"))
+			})
+		})
+
+		Context("when a resource is a kustomization", func() {
+			It("the kustomization in the bundle is accepted", func() {
+				content := signedManifest("  - name: riff\n    kustomize: ./overlays/prod\n")
+				writeManifest(content, sign(signer, []byte(content)))
+				manifest, err = v1alpha1.NewSignedManifest(manifestPath, key)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest.Spec.Resources[0].Kustomize).To(Equal("./overlays/prod"))
+			})
+		})
+
+		Context("when a resource is not pinned", func() {
+			It("an error is returned", func() {
+				content := signedManifest("  - name: ns\n    path: " + server.URL + "/ns.yaml\n")
+				writeManifest(content, sign(signer, []byte(content)))
+				_, err = v1alpha1.NewSignedManifest(manifestPath, key)
+				Expect(err).To(MatchError("resource ns at " + server.URL + "/ns.yaml must have a sha256 or a signature in a signed manifest"))
+			})
+		})
+	})
+})
@@ -17,6 +17,8 @@
 package v1alpha1
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/url"
@@ -24,8 +26,13 @@ import (
 	"strings"
 
 	"github.com/ghodss/yaml"
-	"github.com/pivotal/go-ape/pkg/furl"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/chart"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kustomize"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/oci"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/version"
 )
 
 // +genclient
@@ -49,21 +56,108 @@ type ResourceChecks struct {
 	Pattern   string               `json:"pattern,omitempty"`
 }
 
+// KabResource is installed from its Content, from the content at Path which can be pinned with
+// its Sha256 or with the path of a detached Signature, from a rendered Chart, or from the build of
+// the kustomization directory at the relative path Kustomize. In a manifest with includes, a
+// resource replaces the included resource with the same Name, or removes it when Remove is set.
+// A resource with a Hook is only applied in the hook phase, its jobs are awaited and the resource
+// is deleted afterwards according to its HookDeletePolicy. A Test resource is never installed, it
+// is only run by the test action.
 type KabResource struct {
-	Path     string            `json:"path,omitempty"`
-	Content  string            `json:"content,omitempty"`
-	Name     string            `json:"name,omitempty"`
-	Labels   map[string]string `json:"labels,omitempty"`
-	Deferred bool              `json:"deferred,omitempty"`
-	Checks   []ResourceChecks  `json:"checks,omitempty"`
+	Path      string            `json:"path,omitempty"`
+	Sha256    string            `json:"sha256,omitempty"`
+	Signature string            `json:"signature,omitempty"`
+	Chart     *KabChart         `json:"chart,omitempty"`
+	Kustomize string            `json:"kustomize,omitempty"`
+	Content   string            `json:"content,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Deferred  bool              `json:"deferred,omitempty"`
+	Checks    []ResourceChecks  `json:"checks,omitempty"`
+	Remove    bool              `json:"remove,omitempty"`
+
+	Hook             string `json:"hook,omitempty"`
+	HookDeletePolicy string `json:"hookDeletePolicy,omitempty"`
+	Test             bool   `json:"test,omitempty"`
+}
+
+// KabChart is a helm chart in the bundle, a directory or archive at a relative Path, rendered for a
+// release named ReleaseName, the name of the resource by default, in Namespace, default by default.
+type KabChart struct {
+	Path        string                `json:"path,omitempty"`
+	ReleaseName string                `json:"releaseName,omitempty"`
+	Namespace   string                `json:"namespace,omitempty"`
+	Values      *runtime.RawExtension `json:"values,omitempty"`
+}
+
+// ReleaseNamespace returns the namespace of the release, which the namespaced objects without a
+// namespace are installed into
+func (c *KabChart) ReleaseNamespace() string {
+	if c.Namespace == "" {
+		return metav1.NamespaceDefault
+	}
+	return c.Namespace
+}
+
+// KabOutput is a CNAB output resolved from the cluster after the resources are installed. The value
+// is read from the object with JsonPath, or from the Key of a ConfigMap or Secret.
+type KabOutput struct {
+	Name       string `json:"name,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	ObjectName string `json:"objectName,omitempty"`
+	JsonPath   string `json:"jsonpath,omitempty"`
+	Key        string `json:"key,omitempty"`
+}
+
+// KabNamespace is a namespace created before the resources are installed, with the Labels and
+// Annotations given. A namespace created by the installer is deleted after the other objects on
+// uninstall, a namespace which already existed is only labeled and annotated.
+type KabNamespace struct {
+	Name        string            `json:"name,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// KabRequirements are the capabilities the target cluster must provide, verified by the preflight
+// checks. CPU and Memory are quantities summed over the allocatable resources of the ready nodes.
+type KabRequirements struct {
+	LoadBalancer bool   `json:"loadBalancer,omitempty"`
+	Nodes        int    `json:"nodes,omitempty"`
+	CPU          string `json:"cpu,omitempty"`
+	Memory       string `json:"memory,omitempty"`
 }
 
+// KabSpec is merged over the specs of the manifest files it Includes, which are merged in order.
 type KabSpec struct {
-	Resources []KabResource `json:"resources,omitempty"`
+	Includes             []string         `json:"includes,omitempty"`
+	MinKubernetesVersion string           `json:"minKubernetesVersion,omitempty"`
+	Requirements         *KabRequirements `json:"requirements,omitempty"`
+	Namespaces           []KabNamespace   `json:"namespaces,omitempty"`
+	Resources            []KabResource    `json:"resources,omitempty"`
+	Outputs              []KabOutput      `json:"outputs,omitempty"`
 }
 
+// KabStatus records the Deferred resources of the installation which were installed on demand, and
+// the namespaces the installer created.
 type KabStatus struct {
-	Status string `json:"status,omitempty"`
+	Status            string   `json:"status,omitempty"`
+	InstalledDeferred []string `json:"installedDeferred,omitempty"`
+	CreatedNamespaces []string `json:"createdNamespaces,omitempty"`
+}
+
+// IsInstalled returns true when the resource is installed with the other resources, or is a
+// deferred resource which was installed on demand.
+func (s KabStatus) IsInstalled(resource KabResource) bool {
+	if !resource.Deferred {
+		return resource.Hook == "" && !resource.Test
+	}
+	for _, name := range s.InstalledDeferred {
+		if name == resource.Name {
+			return true
+		}
+	}
+	return false
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -76,13 +170,53 @@ type ManifestList struct {
 }
 
 func NewManifest(path string) (manifest *Manifest, err error) {
-	var m Manifest
-	yamlFile, err := furl.Read(path, "")
+	yamlFile, err := readManifestFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("error reading manifest file: %v", err)
+		return nil, err
 	}
+	return parseManifest(yamlFile, readManifestFile)
+}
 
-	err = yaml.Unmarshal(yamlFile, &m)
+// parseManifest reads the manifest file, resolves its includes with read and validates the result
+func parseManifest(yamlFile []byte, read manifestReader) (*Manifest, error) {
+	m, err := unmarshalManifest(yamlFile)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.resolveIncludes(read, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.VisitResources(checkResourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.VisitResources(checkHook)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, output := range m.Spec.Outputs {
+		err = checkOutput(output)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = checkRequirements(m.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func unmarshalManifest(yamlFile []byte) (*Manifest, error) {
+	var m Manifest
+	err := yaml.Unmarshal(yamlFile, &m)
 	if err != nil {
 		if strings.Contains(err.Error(), "did not find expected key") {
 			return nil, fmt.Errorf("error parsing manifest file: %v. Please ensure that manifest has supported version", err)
@@ -94,23 +228,41 @@ func NewManifest(path string) (manifest *Manifest, err error) {
 	if !strings.EqualFold(m.APIVersion, supportedVersion) {
 		return nil, errors.New(fmt.Sprintf("Unsupported version %s. Supported version is %s", m.APIVersion, supportedVersion))
 	}
-
-	err = m.VisitResources(checkResourcePath)
-	if err != nil {
-		return nil, err
-	}
-
 	return &m, nil
 }
 
+// ResolvedPrefix is prepended to the path of the resources whose content was embedded by
+// InlineContentWith
+const ResolvedPrefix = "kab-resolved:"
+
 // Embeds the contents of Path url into Content field for all resources
 // only when there is no previous content.
 func (m *Manifest) InlineContent() error {
+	return m.InlineContentWith(DefaultResolvers())
+}
+
+// InlineContentWith embeds the content of every resource like InlineContent, reading the paths
+// with a scheme with the resolver registered for the scheme.
+func (m *Manifest) InlineContentWith(resolvers map[string]Resolver) error {
 	err := m.PatchResourceContent(func(res *KabResource) (string, error) {
 		if res.Content != "" {
 			return res.Content, nil
 		}
-		contentBytes, err := furl.Read(res.Path, "")
+		if res.Chart != nil {
+			return res.renderChart()
+		}
+		if res.Kustomize != "" {
+			contentBytes, err := kustomize.Build(res.Kustomize)
+			if err != nil {
+				return "", fmt.Errorf("error building kustomization %s of resource %s: %v", res.Kustomize, res.Name, err)
+			}
+			return string(contentBytes), nil
+		}
+		contentBytes, err := readPath(res.Path, resolvers)
+		if err != nil {
+			return "", err
+		}
+		err = res.verifyDigest(contentBytes)
 		if err != nil {
 			return "", err
 		}
@@ -122,12 +274,95 @@ func (m *Manifest) InlineContent() error {
 
 	for i := 0; i < len(m.Spec.Resources); i++ {
 		resource := &m.Spec.Resources[i]
-		resource.Path = fmt.Sprintf("kab-resolved:%s", resource.Path)
+		resource.Path = ResolvedPrefix + resource.Path
 	}
 
 	return nil
 }
 
+// FillDigests sets the sha256 of every resource with a http, https or oci path which does not have one,
+// from the content currently served at the path. Existing digests are verified. The manifest file
+// is given and returned as yaml, keeping the fields unknown to KabResource.
+func FillDigests(manifestFile []byte) ([]byte, error) {
+	return patchRemoteResources(manifestFile, func(resource map[string]interface{}, res KabResource, content []byte) error {
+		if res.Sha256 == "" {
+			resource["sha256"] = Sha256Digest(content)
+		}
+		return nil
+	})
+}
+
+// patchRemoteResources calls f with every resource of the manifest file with a http, https or oci path,
+// as a map to modify, and with the content at the path, after verifying its digest. The modified
+// manifest file is returned. Manifest files with includes are refused, since the resources of the
+// included manifest files would be left as they are.
+func patchRemoteResources(manifestFile []byte, f func(resource map[string]interface{}, res KabResource, content []byte) error) ([]byte, error) {
+	var m map[string]interface{}
+	err := yaml.Unmarshal(manifestFile, &m)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing manifest file: %v", err)
+	}
+	spec, _ := m["spec"].(map[string]interface{})
+	if includes, _ := spec["includes"].([]interface{}); len(includes) > 0 {
+		return nil, errors.New("manifest file has includes, whose resources would not be rewritten: run on each included manifest file and on the manifest without its includes")
+	}
+	resources, _ := spec["resources"].([]interface{})
+	for _, r := range resources {
+		resource, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		res := KabResource{}
+		res.Path, _ = resource["path"].(string)
+		if !isRemotePath(res.Path) {
+			continue
+		}
+		res.Name, _ = resource["name"].(string)
+		res.Sha256, _ = resource["sha256"].(string)
+		res.Signature, _ = resource["signature"].(string)
+		content, err := readPath(res.Path, DefaultResolvers())
+		if err != nil {
+			return nil, err
+		}
+		err = res.verifyDigest(content)
+		if err != nil {
+			return nil, err
+		}
+		err = f(resource, res, content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return yaml.Marshal(m)
+}
+
+// Sha256Digest returns the hex encoded sha256 of content, as expected in the sha256 of a resource
+func Sha256Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func (res *KabResource) verifyDigest(content []byte) error {
+	if res.Sha256 == "" {
+		return nil
+	}
+	actual := Sha256Digest(content)
+	if !strings.EqualFold(actual, res.Sha256) {
+		return fmt.Errorf("sha256 mismatch for resource %s at %s: expected %s, got %s", res.Name, res.Path, res.Sha256, actual)
+	}
+	return nil
+}
+
+// isRemotePath returns true for the paths read with the default resolvers, outside of the bundle
+func isRemotePath(path string) bool {
+	u, err := url.Parse(path)
+	if err != nil {
+		return false
+	}
+	_, ok := DefaultResolvers()[u.Scheme]
+	return ok
+}
+
 func (m *Manifest) VisitResources(f func(res KabResource) error) error {
 
 	for _, resource := range m.Spec.Resources {
@@ -159,20 +394,124 @@ func (m *Manifest) PatchResourceContent(f func(res *KabResource) (string, error)
 
 func checkResourcePath(resource KabResource) error {
 	if filepath.IsAbs(resource.Path) {
-		return fmt.Errorf("resources must use a http or https URL or a relative path: absolute path not supported: %v", resource)
+		return fmt.Errorf("%s: absolute path not supported: %v", unsupportedPathMessage, resource)
+	}
+
+	if resource.Sha256 != "" {
+		digest, err := hex.DecodeString(resource.Sha256)
+		if err != nil || len(digest) != sha256.Size {
+			return fmt.Errorf("resource %s: sha256 must be 64 hexadecimal characters: %s", resource.Name, resource.Sha256)
+		}
+		if resource.Path == "" {
+			return fmt.Errorf("resource %s: sha256 is only supported for resources with a path", resource.Name)
+		}
+	}
+	if resource.Chart != nil {
+		return checkChart(resource)
+	}
+	if resource.Kustomize != "" {
+		return checkKustomize(resource)
+	}
+	if resource.Signature != "" && resource.Path == "" {
+		return fmt.Errorf("resource %s: signature is only supported for resources with a path", resource.Name)
 	}
 
 	u, err := url.Parse(resource.Path)
 	if err != nil {
 		return err
 	}
-	if u.Scheme == "http" || u.Scheme == "https" || (u.Scheme == "" && !filepath.IsAbs(u.Path)) {
+	switch u.Scheme {
+	case "http", "https":
+		return nil
+	case "oci":
+		_, err = oci.ParseReference(resource.Path)
+		return err
+	case "configmap", "secret":
+		return checkClusterPath(u)
+	case "":
+		if !filepath.IsAbs(u.Path) {
+			return nil
+		}
+		return fmt.Errorf("%s: absolute path not supported: %v", unsupportedPathMessage, resource)
+	}
+
+	return fmt.Errorf("%s: scheme %s not supported: %v", unsupportedPathMessage, u.Scheme, resource)
+}
+
+func checkOutput(output KabOutput) error {
+	if output.Name == "" || output.Kind == "" || output.ObjectName == "" {
+		return fmt.Errorf("outputs must have a name, kind and objectName: %v", output)
+	}
+	if (output.JsonPath == "") == (output.Key == "") {
+		return fmt.Errorf("output %s must have exactly one of jsonpath or key", output.Name)
+	}
+	if output.Key != "" && !strings.EqualFold(output.Kind, "ConfigMap") && !strings.EqualFold(output.Kind, "Secret") {
+		return fmt.Errorf("output %s: key is only supported for ConfigMap and Secret, use jsonpath for %s", output.Name, output.Kind)
+	}
+	return nil
+}
+
+func checkRequirements(spec KabSpec) error {
+	if spec.MinKubernetesVersion != "" {
+		_, err := version.ParseGeneric(spec.MinKubernetesVersion)
+		if err != nil {
+			return fmt.Errorf("invalid minKubernetesVersion: %v", err)
+		}
+	}
+	if spec.Requirements == nil {
 		return nil
 	}
+	if spec.Requirements.CPU != "" {
+		_, err := resource.ParseQuantity(spec.Requirements.CPU)
+		if err != nil {
+			return fmt.Errorf("invalid cpu requirement %s: %v", spec.Requirements.CPU, err)
+		}
+	}
+	if spec.Requirements.Memory != "" {
+		_, err := resource.ParseQuantity(spec.Requirements.Memory)
+		if err != nil {
+			return fmt.Errorf("invalid memory requirement %s: %v", spec.Requirements.Memory, err)
+		}
+	}
+	return nil
+}
 
-	if u.Scheme == "" {
-		return fmt.Errorf("resources must use a http or https URL or a relative path: absolute path not supported: %v", resource)
+func checkChart(resource KabResource) error {
+	if resource.Path != "" || resource.Sha256 != "" || resource.Signature != "" || resource.Kustomize != "" {
+		return fmt.Errorf("resource %s: a chart cannot have a path, sha256, signature or kustomize", resource.Name)
 	}
+	if !isBundlePath(resource.Chart.Path) {
+		return fmt.Errorf("resource %s: the chart must have a relative path to a chart in the bundle: %s", resource.Name, resource.Chart.Path)
+	}
+	return nil
+}
 
-	return fmt.Errorf("resources must use a http or https URL or a relative path: scheme %s not supported: %v", u.Scheme, resource)
+func checkKustomize(resource KabResource) error {
+	if resource.Path != "" || resource.Sha256 != "" || resource.Signature != "" {
+		return fmt.Errorf("resource %s: a kustomization cannot have a path, sha256 or signature", resource.Name)
+	}
+	if !isBundlePath(resource.Kustomize) {
+		return fmt.Errorf("resource %s: kustomize must be a relative path to a directory in the bundle: %s", resource.Name, resource.Kustomize)
+	}
+	return nil
+}
+
+// isBundlePath returns true for relative local paths, which are resolved in the bundle
+func isBundlePath(path string) bool {
+	u, err := url.Parse(path)
+	return err == nil && path != "" && u.Scheme == "" && !filepath.IsAbs(u.Path)
+}
+
+func (res *KabResource) renderChart() (string, error) {
+	releaseName := res.Chart.ReleaseName
+	if releaseName == "" {
+		releaseName = res.Name
+	}
+	namespace := res.Chart.ReleaseNamespace()
+	var values []byte
+	if res.Chart.Values != nil {
+		// the raw json is valid yaml
+		values = res.Chart.Values.Raw
+	}
+	return chart.Render(res.Chart.Path, releaseName, namespace, values)
 }
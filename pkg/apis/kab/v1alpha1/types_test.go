@@ -18,9 +18,12 @@ package v1alpha1_test
 
 import (
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
 	"runtime"
 
+	"github.com/ghodss/yaml"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
@@ -80,7 +83,7 @@ var _ = Describe("Manifest", func() {
 			})
 
 			It("should return a suitable error", func() {
-				Expect(err).To(MatchError(ContainSubstring("resources must use a http or https URL or a relative path: absolute path not supported: ")))
+				Expect(err).To(MatchError(ContainSubstring("resources must use a http, https, oci, configmap or secret URL or a relative path: absolute path not supported: ")))
 			})
 		})
 
@@ -90,7 +93,167 @@ var _ = Describe("Manifest", func() {
 			})
 
 			It("should return a suitable error", func() {
-				Expect(err).To(MatchError(HavePrefix("resources must use a http or https URL or a relative path: scheme file not supported:")))
+				Expect(err).To(MatchError(HavePrefix("resources must use a http, https, oci, configmap or secret URL or a relative path: scheme file not supported:")))
+			})
+		})
+
+		Context("when the manifest contains outputs", func() {
+			BeforeEach(func() {
+				manifestPath = "./fixtures/outputs.yaml"
+			})
+
+			It("should parse the outputs", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest.Spec.Outputs).To(HaveLen(2))
+				Expect(manifest.Spec.Outputs[0].ObjectName).To(Equal("istio-ingressgateway"))
+				Expect(manifest.Spec.Outputs[0].JsonPath).To(Equal("{.status.loadBalancer.ingress[0].ip}"))
+				Expect(manifest.Spec.Outputs[1].Key).To(Equal("token"))
+			})
+		})
+
+		Context("when the manifest contains an invalid output", func() {
+			BeforeEach(func() {
+				manifestPath = "./fixtures/invalid-output.yaml"
+			})
+
+			It("should return a suitable error", func() {
+				Expect(err).To(MatchError("output ingress_ip: key is only supported for ConfigMap and Secret, use jsonpath for Service"))
+			})
+		})
+
+		Context("when the manifest contains an invalid requirement", func() {
+			BeforeEach(func() {
+				manifestPath = "./fixtures/invalid-requirements.yaml"
+			})
+
+			It("should return a suitable error", func() {
+				Expect(err).To(MatchError(HavePrefix("invalid memory requirement lots: ")))
+			})
+		})
+
+		Context("when the manifest contains an invalid sha256", func() {
+			BeforeEach(func() {
+				manifestPath = "./fixtures/invalid-digest.yaml"
+			})
+
+			It("should return a suitable error", func() {
+				Expect(err).To(MatchError("resource istio: sha256 must be 64 hexadecimal characters: not-a-digest"))
+			})
+		})
+
+		Context("when the manifest contains a chart outside of the bundle", func() {
+			BeforeEach(func() {
+				manifestPath = "./fixtures/invalid-chart.yaml"
+			})
+
+			It("should return a suitable error", func() {
+				Expect(err).To(MatchError("resource riff: the chart must have a relative path to a chart in the bundle: https://example.com/charts/riff-0.1.0.tgz"))
+			})
+		})
+
+		Context("when the manifest contains a kustomization with a path", func() {
+			BeforeEach(func() {
+				manifestPath = "./fixtures/invalid-kustomize.yaml"
+			})
+
+			It("should return a suitable error", func() {
+				Expect(err).To(MatchError("resource riff: a kustomization cannot have a path, sha256 or signature"))
+			})
+		})
+
+		Context("when the manifest includes other manifest files", func() {
+			BeforeEach(func() {
+				manifestPath = "./fixtures/include-overlay.yaml"
+			})
+
+			It("should merge the included manifests in order under the overlay", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest.Name).To(Equal("riff-install"))
+				Expect(manifest.Spec.Includes).To(BeEmpty())
+				Expect(manifest.Spec.MinKubernetesVersion).To(Equal("1.15"))
+				Expect(manifest.Spec.Resources).To(Equal([]v1alpha1.KabResource{
+					{Name: "istio", Content: "kind: Namespace"},
+					{Name: "riff-build", Content: "kind: Secret"},
+					{Name: "keda", Content: "kind: Deployment"},
+					{Name: "riff-core", Content: "kind: Pod"},
+				}))
+				Expect(manifest.Spec.Outputs).To(HaveLen(1))
+				Expect(manifest.Spec.Outputs[0].Name).To(Equal("ingress"))
+				Expect(manifest.Spec.Namespaces).To(Equal([]v1alpha1.KabNamespace{
+					{Name: "istio-system"},
+					{Name: "riff-system", Labels: map[string]string{"istio-injection": "enabled"}},
+				}))
+			})
+		})
+
+		Context("when a manifest includes itself", func() {
+			BeforeEach(func() {
+				manifestPath = "./fixtures/include-cycle.yaml"
+			})
+
+			It("should return a suitable error", func() {
+				Expect(err).To(MatchError("manifest ./fixtures/include-cycle.yaml includes itself: ./fixtures/include-cycle.yaml -> ./fixtures/include-cycle.yaml"))
+			})
+		})
+
+		Context("when the manifest removes a resource which is not included", func() {
+			BeforeEach(func() {
+				manifestPath = "./fixtures/include-remove-missing.yaml"
+			})
+
+			It("should return a suitable error", func() {
+				Expect(err).To(MatchError("resource knative cannot be removed: it is not included"))
+			})
+		})
+
+		Context("when an included manifest has an invalid resource", func() {
+			BeforeEach(func() {
+				manifestPath = "./fixtures/include-invalid.yaml"
+			})
+
+			It("should validate the merged manifest", func() {
+				Expect(err).To(MatchError(HavePrefix("resources must use a http, https, oci, configmap or secret URL or a relative path: scheme file not supported:")))
+			})
+		})
+
+		Context("when the manifest contains an unknown hook", func() {
+			BeforeEach(func() {
+				manifestPath = "./fixtures/invalid-hook.yaml"
+			})
+
+			It("should return a suitable error", func() {
+				Expect(err).To(MatchError("resource migrate: unknown hook beforeInstall, supported hooks are [preInstall postInstall preUpgrade postUpgrade preUninstall postUninstall]"))
+			})
+		})
+
+		Context("when a test is also a hook", func() {
+			BeforeEach(func() {
+				manifestPath = "./fixtures/invalid-test.yaml"
+			})
+
+			It("should return a suitable error", func() {
+				Expect(err).To(MatchError("resource smoke: a test cannot be a hook or be deferred"))
+			})
+		})
+
+		Context("when the manifest contains resources read with resolvers", func() {
+			BeforeEach(func() {
+				manifestPath = "./fixtures/resolvers-mfst.yaml"
+			})
+
+			It("should parse the paths", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest.Spec.Resources).To(HaveLen(3))
+			})
+		})
+
+		Context("when the manifest contains an invalid configmap path", func() {
+			BeforeEach(func() {
+				manifestPath = "./fixtures/invalid-configmap.yaml"
+			})
+
+			It("should return a suitable error", func() {
+				Expect(err).To(MatchError("invalid path configmap://riff-system/riff.yaml, expected configmap://namespace/name/key"))
 			})
 		})
 
@@ -230,6 +393,43 @@ var _ = Describe("Manifest", func() {
 				})
 			})
 		})
+		Context("when the resource is a chart", func() {
+			It("the rendered chart is inlined", func() {
+				manifest, err = v1alpha1.NewManifest("./fixtures/chart-mfst.yaml")
+				Expect(err).ToNot(HaveOccurred())
+				err = manifest.InlineContent()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(manifest.Spec.Resources[0].Content).To(ContainSubstring("name: riff-controller"))
+				Expect(manifest.Spec.Resources[0].Content).To(ContainSubstring("namespace: riff-system"))
+				Expect(manifest.Spec.Resources[0].Content).To(ContainSubstring("image: example.com/controller:1"))
+				Expect(manifest.Spec.Resources[0].Content).To(ContainSubstring("type: ClusterIP"))
+			})
+		})
+		Context("when the resource is a kustomization", func() {
+			It("the built kustomization is inlined", func() {
+				manifest, err = v1alpha1.NewManifest("./fixtures/kustomize-mfst.yaml")
+				Expect(err).ToNot(HaveOccurred())
+				err = manifest.InlineContent()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(manifest.Spec.Resources[0].Content).To(ContainSubstring("name: prod-controller"))
+			})
+		})
+		Context("when the resource has a sha256", func() {
+			It("the content is inlined when the digest matches", func() {
+				manifest, err = v1alpha1.NewManifest("./fixtures/inline-mfst-with-digest.yaml")
+				Expect(err).ToNot(HaveOccurred())
+				err = manifest.InlineContent()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(manifest.Spec.Resources[0].Content).To(ContainSubstring("test-ns"))
+			})
+
+			It("an error is returned when the digest does not match", func() {
+				manifest, err = v1alpha1.NewManifest("./fixtures/inline-mfst-digest-mismatch.yaml")
+				Expect(err).ToNot(HaveOccurred())
+				err = manifest.InlineContent()
+				Expect(err).To(MatchError(HavePrefix("sha256 mismatch for resource istio at ./fixtures/inline-ns.yaml: expected 0000")))
+			})
+		})
 		Context("when the resource content is not empty", func() {
 			Context("when there is a valid path specified", func() {
 				It("does not overwrite the resource content", func() {
@@ -244,4 +444,48 @@ var _ = Describe("Manifest", func() {
 			})
 		})
 	})
+
+	Describe("FillDigests", func() {
+
+		var (
+			server *httptest.Server
+			served string
+		)
+
+		BeforeEach(func() {
+			served = "kind: Namespace\n"
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(served))
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		manifestFile := func(resources string) []byte {
+			return []byte("apiVersion: projectriff.io/v1alpha1\nkind: Manifest\nmetadata:\n  name: riff-install\nspec:\n  resources:\n" + resources)
+		}
+
+		It("sets the digest of remote resources", func() {
+			filled, err := v1alpha1.FillDigests(manifestFile("  - name: remote\n    path: " + server.URL + "/ns.yaml\n    custom: kept\n  - name: local\n    path: ./fixtures/inline-ns.yaml\n"))
+			Expect(err).ToNot(HaveOccurred())
+
+			var manifest v1alpha1.Manifest
+			Expect(yaml.Unmarshal(filled, &manifest)).To(Succeed())
+			Expect(manifest.Spec.Resources[0].Sha256).To(Equal(v1alpha1.Sha256Digest([]byte(served))))
+			Expect(manifest.Spec.Resources[1].Sha256).To(BeEmpty())
+			Expect(string(filled)).To(ContainSubstring("custom: kept"))
+		})
+
+		It("fails when an existing digest does not match", func() {
+			_, err := v1alpha1.FillDigests(manifestFile("  - name: remote\n    path: " + server.URL + "/ns.yaml\n    sha256: " + v1alpha1.Sha256Digest([]byte("old")) + "\n"))
+			Expect(err).To(MatchError(HavePrefix("sha256 mismatch for resource remote")))
+		})
+
+		It("fails when the manifest file has includes", func() {
+			_, err := v1alpha1.FillDigests(manifestFile("  - name: remote\n    path: " + server.URL + "/ns.yaml\n  includes:\n  - ./fixtures/base.yaml\n"))
+			Expect(err).To(MatchError(HavePrefix("manifest file has includes")))
+		})
+	})
 })
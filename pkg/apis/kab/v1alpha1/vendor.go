@@ -0,0 +1,140 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"crypto"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// VendorResources downloads the content of every resource with a http or https path, so that the
+// manifest can be installed without access to the internet. With inline, the content is embedded in
+// the manifest and the path, sha256 and signature are removed, the manifest signature covering the
+// content from then on. The signature of a resource is verified before it is dropped, with the key
+// returned by publicKey. Otherwise the content and any remote signature are written to files in dir
+// and the paths rewritten relative to baseDir, the working directory relative paths are resolved
+// from when installing. The manifest file is given and returned as yaml.
+func VendorResources(manifestFile []byte, dir string, baseDir string, inline bool, publicKey func() (crypto.PublicKey, error)) ([]byte, error) {
+	if !inline {
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fileNames := map[string]bool{}
+	return patchRemoteResources(manifestFile, func(resource map[string]interface{}, res KabResource, content []byte) error {
+		if inline {
+			if res.Signature != "" {
+				err := verifyVendoredSignature(res, content, publicKey)
+				if err != nil {
+					return err
+				}
+			}
+			resource["content"] = string(content)
+			delete(resource, "path")
+			delete(resource, "sha256")
+			delete(resource, "signature")
+			return nil
+		}
+
+		fileName := vendoredFileName(res, fileNames)
+		path, err := writeVendoredFile(filepath.Join(dir, fileName), baseDir, content)
+		if err != nil {
+			return fmt.Errorf("error vendoring resource %s: %v", res.Name, err)
+		}
+		resource["path"] = path
+		if isRemotePath(res.Signature) {
+			signature, err := readPath(res.Signature, DefaultResolvers())
+			if err != nil {
+				return fmt.Errorf("error reading signature of resource %s: %v", res.Name, err)
+			}
+			path, err = writeVendoredFile(filepath.Join(dir, fileName+SignatureSuffix), baseDir, signature)
+			if err != nil {
+				return fmt.Errorf("error vendoring signature of resource %s: %v", res.Name, err)
+			}
+			resource["signature"] = path
+		}
+		return nil
+	})
+}
+
+// verifyVendoredSignature checks the content of a resource against its signature, which is lost
+// when the content is inlined
+func verifyVendoredSignature(res KabResource, content []byte, publicKey func() (crypto.PublicKey, error)) error {
+	if publicKey == nil {
+		return fmt.Errorf("resource %s has a signature, a public key is required to verify it before inlining", res.Name)
+	}
+	key, err := publicKey()
+	if err != nil {
+		return err
+	}
+	signature, err := readPath(res.Signature, DefaultResolvers())
+	if err != nil {
+		return fmt.Errorf("error reading signature of resource %s: %v", res.Name, err)
+	}
+	err = VerifySignature(key, content, signature)
+	if err != nil {
+		return fmt.Errorf("resource %s at %s: %v", res.Name, res.Path, err)
+	}
+	return nil
+}
+
+// vendoredFileName returns a file name derived from the resource name, unique among the names taken
+func vendoredFileName(res KabResource, taken map[string]bool) string {
+	base := unsafeFileNameChars.ReplaceAllString(res.Name, "-")
+	if base == "" {
+		base = "resource"
+	}
+	name := base + ".yaml"
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s-%d.yaml", base, i)
+	}
+	taken[name] = true
+	return name
+}
+
+// writeVendoredFile writes content to file and returns its path relative to baseDir
+func writeVendoredFile(file string, baseDir string, content []byte) (string, error) {
+	err := ioutil.WriteFile(file, content, 0644)
+	if err != nil {
+		return "", err
+	}
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	absBase, err := filepath.Abs(baseDir)
+	if err != nil {
+		return "", err
+	}
+	path, err := filepath.Rel(absBase, absFile)
+	if err != nil {
+		return "", err
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "../") {
+		path = "./" + path
+	}
+	return path, nil
+}
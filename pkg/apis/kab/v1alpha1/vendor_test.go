@@ -0,0 +1,137 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1_test
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+)
+
+var _ = Describe("VendorResources", func() {
+
+	var (
+		server       *httptest.Server
+		dir          string
+		manifestFile []byte
+		manifest     v1alpha1.Manifest
+		signer       crypto.Signer
+		publicKey    func() (crypto.PublicKey, error)
+		err          error
+	)
+
+	BeforeEach(func() {
+		manifest = v1alpha1.Manifest{}
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		publicKey = func() (crypto.PublicKey, error) {
+			return signer.Public(), nil
+		}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, v1alpha1.SignatureSuffix) {
+				w.Write(sign(signer, []byte("served "+strings.TrimSuffix(r.URL.Path, v1alpha1.SignatureSuffix))))
+				return
+			}
+			w.Write([]byte("served " + r.URL.Path))
+		}))
+		dir, err = ioutil.TempDir("", "vendor")
+		Expect(err).NotTo(HaveOccurred())
+		manifestFile = []byte("apiVersion: projectriff.io/v1alpha1\nkind: Manifest\nmetadata:\n  name: riff-install\nspec:\n  resources:\n" +
+			"  - name: istio\n    path: " + server.URL + "/istio.yaml\n    signature: " + server.URL + "/istio.yaml.sig\n" +
+			"  - name: local\n    path: ./local.yaml\n" +
+			"  - name: istio\n    path: " + server.URL + "/istio-crds.yaml\n")
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	Context("when the resources are written to files", func() {
+		It("the paths are rewritten relative to the base dir", func() {
+			vendored, err := v1alpha1.VendorResources(manifestFile, filepath.Join(dir, "kab", "resources"), dir, false, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(yaml.Unmarshal(vendored, &manifest)).To(Succeed())
+
+			Expect(manifest.Spec.Resources[0].Path).To(Equal("./kab/resources/istio.yaml"))
+			Expect(manifest.Spec.Resources[0].Signature).To(Equal("./kab/resources/istio.yaml.sig"))
+			Expect(manifest.Spec.Resources[2].Path).To(Equal("./kab/resources/istio-2.yaml"))
+			Expect(manifest.Spec.Resources[1].Path).To(Equal("./local.yaml"))
+
+			content, err := ioutil.ReadFile(filepath.Join(dir, "kab", "resources", "istio-2.yaml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("served /istio-crds.yaml"))
+			signature, err := ioutil.ReadFile(filepath.Join(dir, "kab", "resources", "istio.yaml.sig"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(signature).To(Equal(sign(signer, []byte("served /istio.yaml"))))
+		})
+	})
+
+	Context("when the resources are inlined", func() {
+		It("the content replaces the path", func() {
+			vendored, err := v1alpha1.VendorResources(manifestFile, "", dir, true, publicKey)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(yaml.Unmarshal(vendored, &manifest)).To(Succeed())
+
+			Expect(manifest.Spec.Resources[0].Path).To(BeEmpty())
+			Expect(manifest.Spec.Resources[0].Signature).To(BeEmpty())
+			Expect(manifest.Spec.Resources[0].Content).To(Equal("served /istio.yaml"))
+			Expect(manifest.Spec.Resources[1].Path).To(Equal("./local.yaml"))
+		})
+
+		It("a signature not matching the public key fails", func() {
+			_, other, err := ed25519.GenerateKey(rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = v1alpha1.VendorResources(manifestFile, "", dir, true, func() (crypto.PublicKey, error) {
+				return other.Public(), nil
+			})
+			Expect(err).To(MatchError("resource istio at " + server.URL + "/istio.yaml: invalid signature: verification error"))
+		})
+
+		It("a signature without a public key fails", func() {
+			_, err = v1alpha1.VendorResources(manifestFile, "", dir, true, nil)
+			Expect(err).To(MatchError("resource istio has a signature, a public key is required to verify it before inlining"))
+		})
+	})
+
+	Context("when the manifest file has includes", func() {
+		It("an error is returned", func() {
+			manifestFile = append(manifestFile, []byte("  includes:\n  - "+server.URL+"/base.yaml\n")...)
+			_, err = v1alpha1.VendorResources(manifestFile, dir, dir, false, nil)
+			Expect(err).To(MatchError(HavePrefix("manifest file has includes")))
+		})
+	})
+
+	Context("when a digest does not match", func() {
+		It("an error is returned", func() {
+			manifestFile = append(manifestFile, []byte("    sha256: "+v1alpha1.Sha256Digest([]byte("other"))+"\n")...)
+			_, err = v1alpha1.VendorResources(manifestFile, dir, dir, false, nil)
+			Expect(err).To(MatchError(HavePrefix("sha256 mismatch for resource istio at " + server.URL + "/istio-crds.yaml")))
+		})
+	})
+})
@@ -24,9 +24,97 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KabChart) DeepCopyInto(out *KabChart) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KabChart.
+func (in *KabChart) DeepCopy() *KabChart {
+	if in == nil {
+		return nil
+	}
+	out := new(KabChart)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KabNamespace) DeepCopyInto(out *KabNamespace) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KabNamespace.
+func (in *KabNamespace) DeepCopy() *KabNamespace {
+	if in == nil {
+		return nil
+	}
+	out := new(KabNamespace)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KabOutput) DeepCopyInto(out *KabOutput) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KabOutput.
+func (in *KabOutput) DeepCopy() *KabOutput {
+	if in == nil {
+		return nil
+	}
+	out := new(KabOutput)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KabRequirements) DeepCopyInto(out *KabRequirements) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KabRequirements.
+func (in *KabRequirements) DeepCopy() *KabRequirements {
+	if in == nil {
+		return nil
+	}
+	out := new(KabRequirements)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KabResource) DeepCopyInto(out *KabResource) {
 	*out = *in
+	if in.Chart != nil {
+		in, out := &in.Chart, &out.Chart
+		*out = new(KabChart)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Labels != nil {
 		in, out := &in.Labels, &out.Labels
 		*out = make(map[string]string, len(*in))
@@ -57,6 +145,23 @@ func (in *KabResource) DeepCopy() *KabResource {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KabSpec) DeepCopyInto(out *KabSpec) {
 	*out = *in
+	if in.Includes != nil {
+		in, out := &in.Includes, &out.Includes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Requirements != nil {
+		in, out := &in.Requirements, &out.Requirements
+		*out = new(KabRequirements)
+		**out = **in
+	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]KabNamespace, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Resources != nil {
 		in, out := &in.Resources, &out.Resources
 		*out = make([]KabResource, len(*in))
@@ -64,6 +169,11 @@ func (in *KabSpec) DeepCopyInto(out *KabSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make([]KabOutput, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -80,6 +190,16 @@ func (in *KabSpec) DeepCopy() *KabSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KabStatus) DeepCopyInto(out *KabStatus) {
 	*out = *in
+	if in.InstalledDeferred != nil {
+		in, out := &in.InstalledDeferred, &out.InstalledDeferred
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CreatedNamespaces != nil {
+		in, out := &in.CreatedNamespaces, &out.CreatedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -99,7 +219,7 @@ func (in *Manifest) DeepCopyInto(out *Manifest) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
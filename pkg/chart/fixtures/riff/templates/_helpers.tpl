@@ -0,0 +1,3 @@
+{{- define "riff.fullname" -}}
+{{ .Release.Name }}-controller
+{{- end -}}
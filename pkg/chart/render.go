@@ -0,0 +1,148 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chart
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/helm/pkg/chartutil"
+	hapichart "k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/renderutil"
+)
+
+// installOrder is the order kinds are applied in, the same as helm installs them in. The kinds not
+// listed are applied last.
+var installOrder = []string{
+	"Namespace",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"ServiceAccount",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"Ingress",
+	"APIService",
+}
+
+// Render renders the chart at path, a chart directory or archive, for a release installed in
+// namespace, with the values given as yaml merged over the defaults of the chart. The objects are
+// returned as a multi-document yaml, in the order helm would install them in.
+func Render(chartPath string, releaseName string, namespace string, values []byte) (string, error) {
+	log.Debugf("rendering chart %s as release %s", chartPath, releaseName)
+	c, err := chartutil.Load(chartPath)
+	if err != nil {
+		return "", fmt.Errorf("error loading chart %s: %v", chartPath, err)
+	}
+	if len(values) == 0 {
+		values = []byte("{}")
+	}
+	rendered, err := renderutil.Render(c, &hapichart.Config{Raw: string(values)}, renderutil.Options{
+		ReleaseOptions: chartutil.ReleaseOptions{
+			Name:      releaseName,
+			Namespace: namespace,
+			IsInstall: true,
+			Revision:  1,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error rendering chart %s: %v", chartPath, err)
+	}
+
+	docs := []document{}
+	for _, name := range sortedNames(rendered) {
+		if path.Base(name) == "NOTES.txt" {
+			continue
+		}
+		for _, content := range strings.Split(rendered[name], "\n---") {
+			content = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(content), "---"))
+			if content == "" {
+				continue
+			}
+			kind, err := documentKind(content)
+			if err != nil {
+				return "", fmt.Errorf("error parsing %s of chart %s: %v", name, chartPath, err)
+			}
+			if kind == "" {
+				continue
+			}
+			docs = append(docs, document{kind: kind, content: content})
+		}
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return kindRank(docs[i].kind) < kindRank(docs[j].kind)
+	})
+
+	var b strings.Builder
+	for _, doc := range docs {
+		fmt.Fprintf(&b, "---\n%s\n", doc.content)
+	}
+	return b.String(), nil
+}
+
+type document struct {
+	kind    string
+	content string
+}
+
+func documentKind(content string) (string, error) {
+	var meta struct {
+		Kind string `json:"kind"`
+	}
+	err := yaml.Unmarshal([]byte(content), &meta)
+	return meta.Kind, err
+}
+
+func kindRank(kind string) int {
+	for i, k := range installOrder {
+		if k == kind {
+			return i
+		}
+	}
+	return len(installOrder)
+}
+
+func sortedNames(rendered map[string]string) []string {
+	names := []string{}
+	for name := range rendered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chart_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/chart"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+)
+
+var _ = Describe("Render", func() {
+
+	var (
+		values  []byte
+		content string
+		err     error
+	)
+
+	BeforeEach(func() {
+		values = nil
+	})
+
+	JustBeforeEach(func() {
+		content, err = chart.Render("./fixtures/riff", "my-riff", "riff-system", values)
+	})
+
+	Context("with the default values", func() {
+		It("renders the templates for the release", func() {
+			Expect(err).NotTo(HaveOccurred())
+			objects, err := scan.ListObjectsFromContent([]byte(content))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objects).To(HaveLen(2))
+			Expect(objects[0].GetKind()).To(Equal("Service"))
+			Expect(objects[1].GetKind()).To(Equal("Deployment"))
+			Expect(objects[1].GetName()).To(Equal("my-riff-controller"))
+			Expect(objects[1].GetNamespace()).To(Equal("riff-system"))
+			Expect(content).NotTo(ContainSubstring("riff is installed"))
+		})
+	})
+
+	Context("with values", func() {
+		BeforeEach(func() {
+			values = []byte(`{"createNamespace":true,"image":"example.com/controller:1"}`)
+		})
+
+		It("the values override the defaults of the chart", func() {
+			Expect(err).NotTo(HaveOccurred())
+			objects, err := scan.ListObjectsFromContent([]byte(content))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objects).To(HaveLen(3))
+			Expect(objects[0].GetKind()).To(Equal("Namespace"))
+			Expect(content).To(ContainSubstring("image: example.com/controller:1"))
+			Expect(content).To(ContainSubstring("type: LoadBalancer"))
+		})
+	})
+
+	Context("when the chart does not exist", func() {
+		It("should return a suitable error", func() {
+			_, err = chart.Render("./fixtures/missing", "my-riff", "riff-system", nil)
+			Expect(err).To(MatchError(HavePrefix("error loading chart ./fixtures/missing: ")))
+		})
+	})
+})
@@ -0,0 +1,196 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"crypto"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/client/clientset/versioned"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kustomize"
+	log "github.com/sirupsen/logrus"
+	apiext "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	KUSTOMIZE_TIMEOUT    = 30 * time.Second
+	EVENTS_FLUSH_TIMEOUT = 5 * time.Second
+)
+
+func (opts *options) createKnbClient() (*kab.Client, error) {
+	config, err := opts.getRestConfig()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not get kubernetes configuration: %s", err))
+	}
+	coreClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not create kubernetes core client: %s", err))
+	}
+	extClient, err := apiext.NewForConfig(config)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not create kubernetes extension client: %s", err))
+	}
+	kabClient, err := versioned.NewForConfig(config)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not create kubernetes kab client: %s", err))
+	}
+	kustomizer := kustomize.MakeKustomizer(KUSTOMIZE_TIMEOUT)
+
+	ctl := kubectl.RealKubeCtl(opts.kubectlArgs()...)
+
+	knbClient := kab.NewKnbClient(coreClient, extClient, kabClient, kustomizer, ctl)
+	knbClient.SetNamespace(opts.getNamespace())
+	return knbClient, nil
+}
+
+// createOfflineClient creates a client for operations which do not contact the cluster
+func (opts *options) createOfflineClient() *kab.Client {
+	return kab.NewKnbClient(nil, nil, nil, kustomize.MakeKustomizer(KUSTOMIZE_TIMEOUT), nil)
+}
+
+func (opts *options) getRestConfig() (*rest.Config, error) {
+	config, err := opts.getOutOfClusterRestConfig()
+	if err != nil {
+		if opts.kubeconfig != "" || opts.context != "" {
+			// the cluster was selected explicitly
+			return nil, err
+		}
+		log.Debugln("error getting out of cluster rest config, trying in cluster")
+		config, err = rest.InClusterConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: opts.asUser,
+		Groups:   opts.asGroups,
+	}
+	return config, nil
+}
+
+func (opts *options) getOutOfClusterRestConfig() (*rest.Config, error) {
+	return opts.clientConfig().ClientConfig()
+}
+
+// getNamespace returns the namespace of the kubectl context, or of the service account in cluster,
+// which kubectl applies the namespaced objects without a namespace into
+func (opts *options) getNamespace() string {
+	namespace, _, err := opts.clientConfig().Namespace()
+	if err != nil {
+		log.Debugf("could not get the namespace of the context, using the default namespace: %v", err)
+		return ""
+	}
+	return namespace
+}
+
+func (opts *options) clientConfig() clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = opts.kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: opts.context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+}
+
+// kubectlArgs returns the global kubectl flags selecting the same cluster and identity as the rest config
+func (opts *options) kubectlArgs() []string {
+	args := []string{}
+	if opts.kubeconfig != "" {
+		args = append(args, "--kubeconfig", opts.kubeconfig)
+	}
+	if opts.context != "" {
+		args = append(args, "--context", opts.context)
+	}
+	if opts.asUser != "" {
+		args = append(args, "--as", opts.asUser)
+	}
+	for _, group := range opts.asGroups {
+		args = append(args, "--as-group", group)
+	}
+	return args
+}
+
+// loadManifest reads the manifest and prepares it for installation
+func (opts *options) loadManifest(client *kab.Client) (*v1alpha1.Manifest, error) {
+	manifest, err := opts.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	err = client.PrepareManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// readManifest reads the manifest. When signatures are required, the manifest and the resources
+// with a signature are verified, and the verified content of the resources is inlined.
+func (opts *options) readManifest() (*v1alpha1.Manifest, error) {
+	requireSignature, err := boolParameter(false, REQUIRE_SIGNATURE_ENV_VAR)
+	if err != nil {
+		return nil, err
+	}
+	if !requireSignature {
+		manifest, err := v1alpha1.NewManifest(opts.manifestPath)
+		if err != nil {
+			return nil, fmt.Errorf("error while reading from %s: %v", opts.manifestPath, err)
+		}
+		return manifest, nil
+	}
+
+	key, err := opts.signaturePublicKey()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := v1alpha1.NewSignedManifest(opts.manifestPath, key)
+	if err != nil {
+		return nil, fmt.Errorf("error while verifying %s: %v", opts.manifestPath, err)
+	}
+	err = manifest.InlineSignedContent(key)
+	if err != nil {
+		return nil, fmt.Errorf("error while verifying the resources of %s: %v", opts.manifestPath, err)
+	}
+	log.Infof("verified the signature of %s", opts.manifestPath)
+	return manifest, nil
+}
+
+func (opts *options) signaturePublicKey() (crypto.PublicKey, error) {
+	var pemBytes []byte
+	var err error
+	// not getEnv, the base64 of a key may contain "nil"
+	if opts.publicKey == "" && os.Getenv(SIGNATURE_PUBLIC_KEY_ENV_VAR) != "" {
+		pemBytes = []byte(os.Getenv(SIGNATURE_PUBLIC_KEY_ENV_VAR))
+	} else {
+		path := opts.publicKey
+		if path == "" {
+			path = defaultPublicKeyPath
+		}
+		pemBytes, err = ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("signatures are required but the public key could not be read from $%s or %s: %v", SIGNATURE_PUBLIC_KEY_ENV_VAR, path, err)
+		}
+	}
+	return v1alpha1.ParsePublicKey(pemBytes)
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cluster selection", func() {
+	var (
+		opts *options
+		err  error
+	)
+
+	BeforeEach(func() {
+		opts = &options{}
+	})
+
+	AfterEach(func() {
+		opts.removeTempFiles()
+		os.Unsetenv(KUBECONFIG_DATA_ENV_VAR)
+		os.Unsetenv(KUBE_CONTEXT_ENV_VAR)
+		os.Unsetenv(IMPERSONATE_USER_ENV_VAR)
+		os.Unsetenv(IMPERSONATE_GROUPS_ENV_VAR)
+	})
+
+	Context("when a kubeconfig and context are given", func() {
+		It("the rest config and kubectl use the selected context and identity", func() {
+			opts.kubeconfig = "./fixtures/kubeconfig.yaml"
+			opts.context = "ci"
+			opts.asUser = "jane"
+			opts.asGroups = []string{"admins", "devs"}
+
+			config, err := opts.getRestConfig()
+			Expect(err).To(BeNil())
+			Expect(config.Host).To(Equal("https://ci.example.com"))
+			Expect(config.BearerToken).To(Equal("ci-token"))
+			Expect(config.Impersonate.UserName).To(Equal("jane"))
+			Expect(config.Impersonate.Groups).To(Equal([]string{"admins", "devs"}))
+			Expect(opts.kubectlArgs()).To(Equal([]string{
+				"--kubeconfig", "./fixtures/kubeconfig.yaml",
+				"--context", "ci",
+				"--as", "jane",
+				"--as-group", "admins",
+				"--as-group", "devs",
+			}))
+			Expect(opts.getNamespace()).To(Equal("riff-ci"))
+		})
+
+		It("the namespace falls back to the default namespace", func() {
+			opts.kubeconfig = "./fixtures/kubeconfig.yaml"
+			opts.context = "dev"
+			Expect(opts.getNamespace()).To(Equal("default"))
+		})
+	})
+
+	Context("when the selected context does not exist", func() {
+		It("an error is returned instead of falling back to the in cluster config", func() {
+			opts.kubeconfig = "./fixtures/kubeconfig.yaml"
+			opts.context = "prod"
+			_, err = opts.getRestConfig()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when the kubeconfig credential is given in the environment", func() {
+		It("it is written to a file that is removed afterwards", func() {
+			content, err := ioutil.ReadFile("./fixtures/kubeconfig.yaml")
+			Expect(err).To(BeNil())
+			os.Setenv(KUBECONFIG_DATA_ENV_VAR, string(content))
+			os.Setenv(KUBE_CONTEXT_ENV_VAR, "ci")
+			os.Setenv(IMPERSONATE_USER_ENV_VAR, "jane")
+			os.Setenv(IMPERSONATE_GROUPS_ENV_VAR, "admins,devs")
+
+			err = opts.complete()
+			Expect(err).To(BeNil())
+			Expect(opts.context).To(Equal("ci"))
+			Expect(opts.asUser).To(Equal("jane"))
+			Expect(opts.asGroups).To(Equal([]string{"admins", "devs"}))
+			config, err := opts.getRestConfig()
+			Expect(err).To(BeNil())
+			Expect(config.Host).To(Equal("https://ci.example.com"))
+
+			kubeconfig := opts.kubeconfig
+			opts.removeTempFiles()
+			_, err = os.Stat(kubeconfig)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("Manifest signatures", func() {
+	var opts *options
+
+	BeforeEach(func() {
+		opts = &options{manifestPath: "./fixtures/manifest.yaml"}
+		os.Setenv(REQUIRE_SIGNATURE_ENV_VAR, "true")
+	})
+
+	AfterEach(func() {
+		os.Unsetenv(REQUIRE_SIGNATURE_ENV_VAR)
+	})
+
+	It("the manifest is not read when the public key is missing", func() {
+		opts.publicKey = "./fixtures/no-such-key.pub"
+		_, err := opts.readManifest()
+		Expect(err).To(MatchError(HavePrefix("signatures are required but the public key could not be read")))
+	})
+
+	It("an unsigned manifest is refused", func() {
+		os.Setenv(SIGNATURE_PUBLIC_KEY_ENV_VAR, "-----BEGIN PUBLIC KEY-----\nMCowBQYDK2VwAyEAGb9ECWmEzf6FQbrBZ9w7lshQhqowtrbLDFw4rXAxZuE=\n-----END PUBLIC KEY-----\n")
+		defer os.Unsetenv(SIGNATURE_PUBLIC_KEY_ENV_VAR)
+		_, err := opts.readManifest()
+		Expect(err).To(MatchError(HavePrefix("error while verifying ./fixtures/manifest.yaml: error reading signature of manifest file: ")))
+	})
+})
@@ -0,0 +1,228 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func statusCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Report the health of every resource of an installation",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			knbClient, err := opts.createKnbClient()
+			if err != nil {
+				return err
+			}
+			statuses, err := knbClient.Status(kab.GetInstallationName())
+			if err != nil {
+				return err
+			}
+			err = kab.WriteStatusTable(cmd.OutOrStdout(), statuses)
+			if err != nil {
+				return err
+			}
+			if !kab.IsHealthy(statuses) {
+				return errors.New("installation is not healthy")
+			}
+			return nil
+		},
+	}
+}
+
+func testCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "test",
+		Short: "Run the test resources of an installation in a throwaway namespace",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			knbClient, err := opts.createKnbClient()
+			if err != nil {
+				return err
+			}
+			results, err := knbClient.Test(kab.GetInstallationName())
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			err = kab.WriteTestResults(io.MultiWriter(cmd.OutOrStdout(), &buf), results)
+			if err != nil {
+				return err
+			}
+			err = kab.WriteOutput("test", buf.Bytes())
+			if err != nil {
+				return err
+			}
+			if !kab.TestsPassed(results) {
+				return errors.New("tests failed")
+			}
+			return nil
+		},
+	}
+}
+
+func diffCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff",
+		Short: "Show the differences between the bundle and the objects in the cluster",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			knbClient, err := opts.createKnbClient()
+			if err != nil {
+				return err
+			}
+			manifest, err := opts.loadManifest(knbClient)
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			count, err := knbClient.Diff(manifest, io.MultiWriter(cmd.OutOrStdout(), &buf))
+			if err != nil {
+				return fmt.Errorf("error while comparing %s with the cluster: %v", opts.manifestPath, err)
+			}
+			log.Infof("%d objects differ from the bundle", count)
+			return kab.WriteOutput("diff", buf.Bytes())
+		},
+	}
+}
+
+func renderCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "render",
+		Short: "Print the yaml that would be applied to the cluster, without contacting it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// keep stdout for the rendered yaml
+			log.SetOutput(cmd.OutOrStderr())
+			manifest, err := opts.readManifest()
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			err = opts.createOfflineClient().Render(manifest, io.MultiWriter(cmd.OutOrStdout(), &buf))
+			if err != nil {
+				return fmt.Errorf("error while rendering %s: %v", opts.manifestPath, err)
+			}
+			return kab.WriteOutput("render", buf.Bytes())
+		},
+	}
+}
+
+func validateCommand(opts *options) *cobra.Command {
+	var fillDigests bool
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the manifest and the content of its resources, reporting every problem found",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if fillDigests {
+				err := fillManifestDigests(opts.manifestPath)
+				if err != nil {
+					return err
+				}
+			}
+			manifest, err := opts.readManifest()
+			if err != nil {
+				return err
+			}
+			problems := opts.createOfflineClient().Validate(manifest)
+			var buf bytes.Buffer
+			if len(problems) == 0 {
+				_, err = fmt.Fprintf(io.MultiWriter(cmd.OutOrStdout(), &buf), "manifest %s is valid\n", opts.manifestPath)
+			} else {
+				err = kab.WriteValidationProblems(io.MultiWriter(cmd.OutOrStdout(), &buf), problems)
+			}
+			if err != nil {
+				return err
+			}
+			err = kab.WriteOutput("validate", buf.Bytes())
+			if err != nil {
+				return err
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("manifest %s has %d problems", opts.manifestPath, len(problems))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&fillDigests, "fill-digests", false, "write the sha256 of the content of every remote resource without one into the manifest")
+	return cmd
+}
+
+func fillManifestDigests(manifestPath string) error {
+	manifestFile, err := readLocalManifest(manifestPath, "fill the digests of")
+	if err != nil {
+		return err
+	}
+	filled, err := v1alpha1.FillDigests(manifestFile)
+	if err != nil {
+		return fmt.Errorf("error while filling the digests of %s: %v", manifestPath, err)
+	}
+	log.Infof("writing the resource digests to %s", manifestPath)
+	return ioutil.WriteFile(manifestPath, filled, 0644)
+}
+
+// readLocalManifest reads a manifest file which is about to be rewritten by the operation
+func readLocalManifest(manifestPath string, operation string) ([]byte, error) {
+	u, err := url.Parse(manifestPath)
+	if err != nil || u.Scheme != "" {
+		return nil, fmt.Errorf("cannot %s %s: the manifest must be a local file", operation, manifestPath)
+	}
+	manifestFile, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest file: %v", err)
+	}
+	return manifestFile, nil
+}
+
+func rbacCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rbac",
+		Short: "Print the least privileged roles needed to install and uninstall the bundle",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// keep stdout for the generated yaml
+			log.SetOutput(cmd.OutOrStderr())
+			manifest, err := opts.readManifest()
+			if err != nil {
+				return err
+			}
+			err = manifest.InlineContent()
+			if err != nil {
+				return fmt.Errorf("error while reading manifest: %v", err)
+			}
+			var buf bytes.Buffer
+			err = kab.WriteRBAC(manifest, io.MultiWriter(cmd.OutOrStdout(), &buf))
+			if err != nil {
+				return fmt.Errorf("error while generating roles for %s: %v", opts.manifestPath, err)
+			}
+			return kab.WriteOutput("rbac", buf.Bytes())
+		},
+	}
+}
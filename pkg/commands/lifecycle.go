@@ -0,0 +1,214 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func installCommand(opts *options) *cobra.Command {
+	var dryRunFlag bool
+	var skipPreflightFlag bool
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install the bundle",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dryRun, err := isDryRun(dryRunFlag)
+			if err != nil {
+				return err
+			}
+			skipPreflight, err := boolParameter(skipPreflightFlag, SKIP_PREFLIGHT_ENV_VAR)
+			if err != nil {
+				return err
+			}
+			return install(opts, cmd.OutOrStdout(), dryRun, !skipPreflight)
+		},
+	}
+	cmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "submit the resources with server-side dry-run instead of applying them")
+	cmd.Flags().BoolVar(&skipPreflightFlag, "skip-preflight", false, "install without running the preflight checks first")
+	return cmd
+}
+
+func dryRunCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "dry-run",
+		Short: "Report what an install would create or change without modifying the cluster",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return install(opts, cmd.OutOrStdout(), true, false)
+		},
+	}
+}
+
+func install(opts *options, out io.Writer, dryRun bool, preflight bool) error {
+	log.Debugf("installing manifest file: %s, dry-run: %t", opts.manifestPath, dryRun)
+	knbClient, err := opts.createKnbClient()
+	if err != nil {
+		return err
+	}
+	manifest, err := opts.loadManifest(knbClient)
+	if err != nil {
+		return err
+	}
+	if preflight && !dryRun {
+		err = runPreflight(knbClient, manifest, out)
+		if err != nil {
+			return err
+		}
+	}
+	if dryRun {
+		err = knbClient.DryRunInstall(manifest)
+	} else {
+		knbClient.RecordEvents()
+		defer knbClient.FlushEvents(EVENTS_FLUSH_TIMEOUT)
+		err = knbClient.Install(manifest)
+	}
+	if err != nil {
+		return fmt.Errorf("error while installing from %s: %v", opts.manifestPath, err)
+	}
+	return nil
+}
+
+func installDeferredCommand(opts *options) *cobra.Command {
+	var resources []string
+	cmd := &cobra.Command{
+		Use:   "install-deferred",
+		Short: "Install deferred resources of an installation",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(resources) == 0 && getEnv(DEFERRED_RESOURCES_ENV_VAR) != "" {
+				resources = strings.Split(getEnv(DEFERRED_RESOURCES_ENV_VAR), ",")
+			}
+			knbClient, err := opts.createKnbClient()
+			if err != nil {
+				return err
+			}
+			knbClient.RecordEvents()
+			defer knbClient.FlushEvents(EVENTS_FLUSH_TIMEOUT)
+			return knbClient.InstallDeferred(kab.GetInstallationName(), resources)
+		},
+	}
+	cmd.Flags().StringArrayVar(&resources, "resource", nil, fmt.Sprintf("name of a deferred resource to install, may be repeated (default comma separated $%s)", DEFERRED_RESOURCES_ENV_VAR))
+	return cmd
+}
+
+func upgradeCommand(opts *options) *cobra.Command {
+	var dryRunFlag bool
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade an installation to the bundle",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dryRun, err := isDryRun(dryRunFlag)
+			if err != nil {
+				return err
+			}
+			log.Debugf("upgrading manifest file: %s, dry-run: %t", opts.manifestPath, dryRun)
+			knbClient, err := opts.createKnbClient()
+			if err != nil {
+				return err
+			}
+			manifest, err := opts.loadManifest(knbClient)
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				err = knbClient.DryRunUpgrade(manifest)
+			} else {
+				knbClient.RecordEvents()
+				defer knbClient.FlushEvents(EVENTS_FLUSH_TIMEOUT)
+				err = knbClient.Upgrade(manifest)
+			}
+			if err != nil {
+				return fmt.Errorf("error while upgrading from %s: %v", opts.manifestPath, err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "submit the resources with server-side dry-run instead of applying them")
+	return cmd
+}
+
+func uninstallCommand(opts *options) *cobra.Command {
+	var dryRunFlag bool
+	cmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Uninstall an installation",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dryRun, err := isDryRun(dryRunFlag)
+			if err != nil {
+				return err
+			}
+			knbClient, err := opts.createKnbClient()
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				return knbClient.DryRunUninstall(kab.GetInstallationName())
+			}
+			knbClient.RecordEvents()
+			defer knbClient.FlushEvents(EVENTS_FLUSH_TIMEOUT)
+			return knbClient.Uninstall(kab.GetInstallationName())
+		},
+	}
+	cmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "report the objects that would be deleted instead of deleting them")
+	return cmd
+}
+
+func preflightCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "preflight",
+		Short: "Check that the bundle can be installed on the cluster",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			knbClient, err := opts.createKnbClient()
+			if err != nil {
+				return err
+			}
+			manifest, err := opts.loadManifest(knbClient)
+			if err != nil {
+				return err
+			}
+			return runPreflight(knbClient, manifest, cmd.OutOrStdout())
+		},
+	}
+}
+
+func runPreflight(knbClient *kab.Client, manifest *v1alpha1.Manifest, out io.Writer) error {
+	results, err := knbClient.Preflight(manifest)
+	if err != nil {
+		return fmt.Errorf("error while running preflight checks: %v", err)
+	}
+	err = kab.WritePreflightReport(out, results)
+	if err != nil {
+		return err
+	}
+	if !kab.PreflightSucceeded(results) {
+		return errors.New("preflight checks failed")
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	CNAB_ACTION_ENV_VAR    = "CNAB_ACTION"
+	MANIFEST_FILE_ENV_VAR  = "MANIFEST_FILE"
+	LOG_LEVEL_ENV_VAR      = "LOG_LEVEL"
+	LOG_FORMAT_ENV_VAR     = "LOG_FORMAT"
+	DRY_RUN_ENV_VAR        = "DRY_RUN"
+	SKIP_PREFLIGHT_ENV_VAR = "SKIP_PREFLIGHT"
+
+	// the deferred resources installed by the install-deferred action, comma separated
+	DEFERRED_RESOURCES_ENV_VAR = "DEFERRED_RESOURCES"
+
+	// signatures are verified with the public key credential, or the key shipped in the invocation image
+	REQUIRE_SIGNATURE_ENV_VAR    = "REQUIRE_SIGNATURE"
+	SIGNATURE_PUBLIC_KEY_ENV_VAR = "SIGNATURE_PUBLIC_KEY"
+	defaultPublicKeyPath         = "/cnab/app/kab/signature.pub"
+
+	// the kubeconfig credential can be delivered as a file, at the default kubeconfig location or
+	// the path in $KUBECONFIG, or with its contents in an env var
+	KUBECONFIG_DATA_ENV_VAR    = "KUBECONFIG_DATA"
+	KUBE_CONTEXT_ENV_VAR       = "KUBE_CONTEXT"
+	IMPERSONATE_USER_ENV_VAR   = "IMPERSONATE_USER"
+	IMPERSONATE_GROUPS_ENV_VAR = "IMPERSONATE_GROUPS"
+
+	// revert after duffle fixes the export parameter issue
+	// https://github.com/deislabs/duffle/issues/753
+	defaultManifestPath = "/cnab/app/kab/manifest.yaml"
+)
+
+// parameters maps the bundle parameters that can be passed with --param to the environment
+// variables a CNAB runtime delivers them in
+var parameters = map[string]string{
+	"node_port":          kab.NODE_PORT_ENV_VAR,
+	"dry_run":            DRY_RUN_ENV_VAR,
+	"skip_preflight":     SKIP_PREFLIGHT_ENV_VAR,
+	"log_format":         LOG_FORMAT_ENV_VAR,
+	"manifest_file":      MANIFEST_FILE_ENV_VAR,
+	"kube_context":       KUBE_CONTEXT_ENV_VAR,
+	"impersonate_user":   IMPERSONATE_USER_ENV_VAR,
+	"impersonate_groups": IMPERSONATE_GROUPS_ENV_VAR,
+	"require_signature":  REQUIRE_SIGNATURE_ENV_VAR,
+	"deferred_resources": DEFERRED_RESOURCES_ENV_VAR,
+	"target_namespace":   kab.TARGET_NAMESPACE_ENV_VAR,
+}
+
+type options struct {
+	manifestPath string
+	kubeconfig   string
+	context      string
+	name         string
+	params       []string
+	logLevel     string
+	logFormat    string
+	asUser       string
+	asGroups     []string
+	publicKey    string
+
+	// files to remove once the command completes
+	tempFiles []string
+}
+
+func CreateKabCommand() *cobra.Command {
+	opts := &options{}
+
+	root := &cobra.Command{
+		Use:           "kab",
+		Short:         "Install and manage Kubernetes Application Bundles",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.complete()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return errors.New("unknown action ''. please set CNAB_ACTION environment variable")
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&opts.manifestPath, "manifest", "", fmt.Sprintf("path or url of the manifest file (default $%s or %s)", MANIFEST_FILE_ENV_VAR, defaultManifestPath))
+	flags.StringVar(&opts.kubeconfig, "kubeconfig", "", fmt.Sprintf("path to the kubeconfig file (default contents of $%s, $KUBECONFIG or ~/.kube/config)", KUBECONFIG_DATA_ENV_VAR))
+	flags.StringVar(&opts.context, "context", "", fmt.Sprintf("name of the kubeconfig context to use (default $%s or current context)", KUBE_CONTEXT_ENV_VAR))
+	flags.StringVar(&opts.asUser, "as", "", fmt.Sprintf("user to impersonate (default $%s)", IMPERSONATE_USER_ENV_VAR))
+	flags.StringArrayVar(&opts.asGroups, "as-group", nil, fmt.Sprintf("group to impersonate, may be repeated (default comma separated $%s)", IMPERSONATE_GROUPS_ENV_VAR))
+	flags.StringVar(&opts.name, "name", "", fmt.Sprintf("name of the installation (default $%s)", kab.CNAB_INSTALLATION_NAME_ENV_VAR))
+	flags.StringArrayVar(&opts.params, "param", nil, fmt.Sprintf("bundle parameter as name=value, may be repeated (supported: %s)", strings.Join(parameterNames(), ", ")))
+	flags.StringVar(&opts.publicKey, "public-key", "", fmt.Sprintf("path of the PEM public key verifying signatures when %s is set (default contents of $%s or %s)", REQUIRE_SIGNATURE_ENV_VAR, SIGNATURE_PUBLIC_KEY_ENV_VAR, defaultPublicKeyPath))
+	flags.StringVar(&opts.logLevel, "log-level", "", fmt.Sprintf("log level (default $%s or info)", LOG_LEVEL_ENV_VAR))
+	flags.StringVar(&opts.logFormat, "log-format", "", fmt.Sprintf("log format, text or json (default $%s or text)", LOG_FORMAT_ENV_VAR))
+
+	root.AddCommand(
+		installCommand(opts),
+		installDeferredCommand(opts),
+		dryRunCommand(opts),
+		preflightCommand(opts),
+		upgradeCommand(opts),
+		uninstallCommand(opts),
+		statusCommand(opts),
+		testCommand(opts),
+		diffCommand(opts),
+		renderCommand(opts),
+		validateCommand(opts),
+		rbacCommand(opts),
+		vendorCommand(opts),
+		imagesCommand(opts),
+	)
+	for _, cmd := range append(root.Commands(), root) {
+		runE := cmd.RunE
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			defer opts.removeTempFiles()
+			return runE(cmd, args)
+		}
+	}
+	return root
+}
+
+// Args returns the command line arguments. When invoked by a CNAB runtime without arguments, the
+// CNAB action is used as the command.
+func Args(osArgs []string) []string {
+	if len(osArgs) > 0 {
+		return osArgs
+	}
+	action := strings.ToLower(getEnv(CNAB_ACTION_ENV_VAR))
+	if action == "" {
+		return osArgs
+	}
+	return []string{action}
+}
+
+// complete resolves the options not given as flags from the CNAB environment variables and exports
+// the flags the kab package reads from the environment
+func (opts *options) complete() error {
+	level, err := logLevel(opts.logLevel)
+	if err != nil {
+		return err
+	}
+	log.SetLevel(level)
+
+	for _, param := range opts.params {
+		parts := strings.SplitN(param, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid parameter %q, expected name=value", param)
+		}
+		envVar, ok := parameters[parts[0]]
+		if !ok {
+			return fmt.Errorf("unknown parameter %q, supported parameters are: %s", parts[0], strings.Join(parameterNames(), ", "))
+		}
+		err = os.Setenv(envVar, parts[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	formatter, err := logFormatter(opts.logFormat)
+	if err != nil {
+		return err
+	}
+	log.SetFormatter(formatter)
+
+	if opts.name != "" {
+		err = os.Setenv(kab.CNAB_INSTALLATION_NAME_ENV_VAR, opts.name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.manifestPath == "" {
+		opts.manifestPath = getEnv(MANIFEST_FILE_ENV_VAR)
+	}
+	if opts.manifestPath == "" {
+		opts.manifestPath = defaultManifestPath
+	}
+
+	if opts.context == "" {
+		opts.context = getEnv(KUBE_CONTEXT_ENV_VAR)
+	}
+	if opts.asUser == "" {
+		opts.asUser = getEnv(IMPERSONATE_USER_ENV_VAR)
+	}
+	if len(opts.asGroups) == 0 && getEnv(IMPERSONATE_GROUPS_ENV_VAR) != "" {
+		opts.asGroups = strings.Split(getEnv(IMPERSONATE_GROUPS_ENV_VAR), ",")
+	}
+	if opts.kubeconfig == "" && getEnv(KUBECONFIG_DATA_ENV_VAR) != "" {
+		// kubectl only reads kubeconfig from files
+		opts.kubeconfig, err = opts.writeTempFile("kubeconfig", getEnv(KUBECONFIG_DATA_ENV_VAR))
+		if err != nil {
+			return fmt.Errorf("could not write kubeconfig from $%s: %v", KUBECONFIG_DATA_ENV_VAR, err)
+		}
+	}
+	return nil
+}
+
+func (opts *options) writeTempFile(prefix string, content string) (string, error) {
+	file, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	opts.tempFiles = append(opts.tempFiles, file.Name())
+	_, err = file.WriteString(content)
+	return file.Name(), err
+}
+
+func (opts *options) removeTempFiles() {
+	for _, file := range opts.tempFiles {
+		err := os.Remove(file)
+		if err != nil {
+			log.Debugf("could not remove %s: %v", file, err)
+		}
+	}
+	opts.tempFiles = nil
+}
+
+func logLevel(requestedLevel string) (log.Level, error) {
+	if requestedLevel == "" {
+		requestedLevel = getEnv(LOG_LEVEL_ENV_VAR)
+	}
+	if requestedLevel == "" {
+		return log.InfoLevel, nil
+	}
+	level, err := log.ParseLevel(requestedLevel)
+	if err != nil {
+		return level, fmt.Errorf("Unknown log level %s", requestedLevel)
+	}
+	return level, nil
+}
+
+func logFormatter(requestedFormat string) (log.Formatter, error) {
+	if requestedFormat == "" {
+		requestedFormat = getEnv(LOG_FORMAT_ENV_VAR)
+	}
+	switch strings.ToLower(requestedFormat) {
+	case "", "text":
+		return &log.TextFormatter{}, nil
+	case "json":
+		return &log.JSONFormatter{}, nil
+	}
+	return nil, fmt.Errorf("Unknown log format %s", requestedFormat)
+}
+
+// isDryRun returns true when the flag or the dry_run parameter are set
+func isDryRun(flag bool) (bool, error) {
+	return boolParameter(flag, DRY_RUN_ENV_VAR)
+}
+
+// boolParameter returns true when the flag or the boolean parameter in envVar are set
+func boolParameter(flag bool, envVar string) (bool, error) {
+	if flag {
+		return true, nil
+	}
+	value := getEnv(envVar)
+	if value == "" {
+		return false, nil
+	}
+	retVal, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("Invalid value for %s: %s", envVar, value)
+	}
+	return retVal, nil
+}
+
+func parameterNames() []string {
+	names := []string{}
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// duffle sets the env value to "<nil>", so restore normal behavior
+func getEnv(env_var string) string {
+	val := os.Getenv(env_var)
+	if strings.Contains(val, "nil") {
+		return ""
+	}
+	return val
+}
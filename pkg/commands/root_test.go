@@ -0,0 +1,169 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/commands"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("Kab Command", func() {
+
+	var (
+		cmd *cobra.Command
+		out *bytes.Buffer
+		err error
+	)
+
+	BeforeEach(func() {
+		cmd = commands.CreateKabCommand()
+		out = &bytes.Buffer{}
+		cmd.SetOutput(out)
+	})
+
+	AfterEach(func() {
+		os.Unsetenv(commands.CNAB_ACTION_ENV_VAR)
+		os.Unsetenv(commands.MANIFEST_FILE_ENV_VAR)
+		os.Unsetenv(kab.NODE_PORT_ENV_VAR)
+		os.Unsetenv(kab.CNAB_INSTALLATION_NAME_ENV_VAR)
+	})
+
+	Describe("Args", func() {
+		It("uses the command line arguments when given", func() {
+			os.Setenv(commands.CNAB_ACTION_ENV_VAR, "install")
+			Expect(commands.Args([]string{"status"})).To(Equal([]string{"status"}))
+		})
+
+		It("falls back to the CNAB action", func() {
+			os.Setenv(commands.CNAB_ACTION_ENV_VAR, "Uninstall")
+			Expect(commands.Args([]string{})).To(Equal([]string{"uninstall"}))
+		})
+
+		It("ignores the value duffle sets for an unset action", func() {
+			os.Setenv(commands.CNAB_ACTION_ENV_VAR, "<nil>")
+			Expect(commands.Args([]string{})).To(BeEmpty())
+		})
+	})
+
+	Context("when no action is given", func() {
+		It("an error is returned", func() {
+			cmd.SetArgs([]string{})
+			err = cmd.Execute()
+			Expect(err).To(MatchError("unknown action ''. please set CNAB_ACTION environment variable"))
+		})
+	})
+
+	Context("when the log format is unknown", func() {
+		It("an error is returned", func() {
+			cmd.SetArgs([]string{"validate", "--manifest", "./fixtures/manifest.yaml", "--log-format", "xml"})
+			err = cmd.Execute()
+			Expect(err).To(MatchError("Unknown log format xml"))
+		})
+	})
+
+	Context("when the manifest is valid", func() {
+		It("validate reports it", func() {
+			cmd.SetArgs([]string{"validate", "--manifest", "./fixtures/manifest.yaml"})
+			err = cmd.Execute()
+			Expect(err).To(BeNil())
+			Expect(out.String()).To(Equal("manifest ./fixtures/manifest.yaml is valid\n"))
+		})
+
+		It("the manifest path falls back to the environment", func() {
+			os.Setenv(commands.MANIFEST_FILE_ENV_VAR, "./fixtures/manifest.yaml")
+			cmd.SetArgs([]string{"validate"})
+			err = cmd.Execute()
+			Expect(err).To(BeNil())
+			Expect(out.String()).To(ContainSubstring("./fixtures/manifest.yaml is valid"))
+		})
+	})
+
+	Context("when a resource content is invalid", func() {
+		It("validate returns an error", func() {
+			cmd.SetArgs([]string{"validate", "--manifest", "./fixtures/invalid-content.yaml"})
+			err = cmd.Execute()
+			Expect(err).To(MatchError("manifest ./fixtures/invalid-content.yaml has 1 problems"))
+			Expect(out.String()).To(HavePrefix("resource broken, document 1, line 2: invalid yaml: "))
+		})
+	})
+
+	Context("when parameters and a name are given", func() {
+		It("they are exported to the environment", func() {
+			cmd.SetArgs([]string{"validate", "--manifest", "./fixtures/manifest.yaml", "--param", "node_port=true", "--name", "my-install"})
+			err = cmd.Execute()
+			Expect(err).To(BeNil())
+			Expect(os.Getenv(kab.NODE_PORT_ENV_VAR)).To(Equal("true"))
+			Expect(os.Getenv(kab.CNAB_INSTALLATION_NAME_ENV_VAR)).To(Equal("my-install"))
+		})
+
+		It("an unknown parameter is rejected", func() {
+			cmd.SetArgs([]string{"validate", "--manifest", "./fixtures/manifest.yaml", "--param", "foo=bar"})
+			err = cmd.Execute()
+			Expect(err).To(MatchError("unknown parameter \"foo\", supported parameters are: deferred_resources, dry_run, impersonate_groups, impersonate_user, kube_context, log_format, manifest_file, node_port, require_signature, skip_preflight, target_namespace"))
+		})
+
+		It("a parameter without a value is rejected", func() {
+			cmd.SetArgs([]string{"validate", "--manifest", "./fixtures/manifest.yaml", "--param", "node_port"})
+			err = cmd.Execute()
+			Expect(err).To(MatchError("invalid parameter \"node_port\", expected name=value"))
+		})
+	})
+
+	Context("when the images of a vendored manifest are written", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir, err = ioutil.TempDir("", "vendor")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		vendorImages := func(resource string) string {
+			manifestPath := filepath.Join(dir, "manifest.yaml")
+			content := "apiVersion: projectriff.io/v1alpha1\nkind: Manifest\nmetadata:\n  name: test-install\nspec:\n  resources:\n" + resource
+			Expect(ioutil.WriteFile(manifestPath, []byte(content), 0644)).To(Succeed())
+			imagesFile := filepath.Join(dir, "images.json")
+			cmd.SetArgs([]string{"vendor", "--manifest", manifestPath, "--base-dir", "..", "--images", imagesFile})
+			err = cmd.Execute()
+			Expect(err).NotTo(HaveOccurred())
+			images, err := ioutil.ReadFile(imagesFile)
+			Expect(err).NotTo(HaveOccurred())
+			return string(images)
+		}
+
+		It("the kustomizations are built from the base directory", func() {
+			images := vendorImages("    - name: riff\n      kustomize: ./kustomize/fixtures/overlays/prod\n")
+			Expect(images).To(ContainSubstring("projectriff/controller:0.1"))
+		})
+
+		It("the charts are rendered from the base directory", func() {
+			images := vendorImages("    - name: riff\n      chart:\n        path: ./chart/fixtures/riff\n")
+			Expect(images).To(ContainSubstring("projectriff/controller:0.1"))
+		})
+	})
+})
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	"github.com/pivotal/go-ape/pkg/furl"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func vendorCommand(opts *options) *cobra.Command {
+	var dir, baseDir, output, imagesFile string
+	var imagePaths []string
+	var inline bool
+	cmd := &cobra.Command{
+		Use:   "vendor",
+		Short: "Download the remote resources of the manifest into the bundle, for installing without internet access",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifestFile, err := readLocalManifest(opts.manifestPath, "vendor the resources of")
+			if err != nil {
+				return err
+			}
+			if dir == "" {
+				dir = filepath.Join(filepath.Dir(opts.manifestPath), "resources")
+			}
+			if output == "" {
+				output = opts.manifestPath
+			}
+			vendored, err := v1alpha1.VendorResources(manifestFile, dir, baseDir, inline, opts.signaturePublicKey)
+			if err != nil {
+				return fmt.Errorf("error while vendoring the resources of %s: %v", opts.manifestPath, err)
+			}
+			log.Infof("writing the vendored manifest to %s", output)
+			err = ioutil.WriteFile(output, vendored, 0644)
+			if err != nil {
+				return err
+			}
+			if imagesFile == "" {
+				return nil
+			}
+			paths, err := parseImagePaths(imagePaths)
+			if err != nil {
+				return err
+			}
+			images, err := vendoredImages(vendored, baseDir, paths)
+			if err != nil {
+				return err
+			}
+			log.Infof("writing %d images to %s", len(images), imagesFile)
+			var buf bytes.Buffer
+			err = kab.WriteBundleImages(&buf, images)
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(imagesFile, buf.Bytes(), 0644)
+		},
+	}
+	cmd.Flags().StringVar(&dir, "dir", "", "directory the resources are written to (default resources next to the manifest)")
+	cmd.Flags().StringVar(&baseDir, "base-dir", ".", "directory relative resource paths are resolved from when installing, the written paths are relative to it")
+	cmd.Flags().BoolVar(&inline, "inline", false, "embed the resources in the content of the manifest instead of writing files, verifying their signatures with --public-key")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file the vendored manifest is written to (default the manifest file)")
+	cmd.Flags().StringVar(&imagesFile, "images", "", "file the images section of the bundle is written to, listing the images of the resources for relocation")
+	cmd.Flags().StringArrayVar(&imagePaths, "image-path", nil, "kind=jsonpath of images outside of pod specs, may be repeated")
+	return cmd
+}
+
+// vendoredImages lists the images of every resource of a vendored manifest file, reading the
+// relative paths, charts and kustomizations from baseDir
+func vendoredImages(manifestFile []byte, baseDir string, paths []scan.ImagePath) ([]string, error) {
+	var manifest v1alpha1.Manifest
+	err := yaml.Unmarshal(manifestFile, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing manifest file: %v", err)
+	}
+	baseDir, err = filepath.Abs(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	for i := range manifest.Spec.Resources {
+		res := &manifest.Spec.Resources[i]
+		if res.Chart != nil {
+			res.Chart.Path = filepath.Join(baseDir, res.Chart.Path)
+		}
+		if res.Kustomize != "" {
+			res.Kustomize = filepath.Join(baseDir, res.Kustomize)
+		}
+	}
+	err = manifest.PatchResourceContent(func(res *v1alpha1.KabResource) (string, error) {
+		if res.Content != "" || res.Path == "" {
+			return res.Content, nil
+		}
+		content, err := furl.Read(res.Path, baseDir)
+		if err != nil {
+			return "", fmt.Errorf("error reading resource %s: %v", res.Name, err)
+		}
+		return string(content), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// builds the charts and kustomizations
+	err = manifest.InlineContent()
+	if err != nil {
+		return nil, err
+	}
+	return kab.ManifestImages(&manifest, paths...)
+}
+
+func imagesCommand(opts *options) *cobra.Command {
+	var imagePaths []string
+	var format string
+	cmd := &cobra.Command{
+		Use:   "images",
+		Short: "Print the container images of the resources, as the images section of the bundle",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// keep stdout for the images
+			log.SetOutput(cmd.OutOrStderr())
+			paths, err := parseImagePaths(imagePaths)
+			if err != nil {
+				return err
+			}
+			manifest, err := opts.readManifest()
+			if err != nil {
+				return err
+			}
+			err = manifest.InlineContent()
+			if err != nil {
+				return fmt.Errorf("error while reading manifest: %v", err)
+			}
+			images, err := kab.ManifestImages(manifest, paths...)
+			if err != nil {
+				return err
+			}
+			switch format {
+			case "json":
+				return kab.WriteBundleImages(cmd.OutOrStdout(), images)
+			case "list":
+				for _, image := range images {
+					_, err = fmt.Fprintln(cmd.OutOrStdout(), image)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			default:
+				return fmt.Errorf("unknown images format %s, expected json or list", format)
+			}
+		},
+	}
+	cmd.Flags().StringArrayVar(&imagePaths, "image-path", nil, "kind=jsonpath of images outside of pod specs, may be repeated")
+	cmd.Flags().StringVar(&format, "format", "json", "json for the images section of the bundle, or list for one image per line")
+	return cmd
+}
+
+func parseImagePaths(values []string) ([]scan.ImagePath, error) {
+	paths := []scan.ImagePath{}
+	for _, value := range values {
+		path, err := scan.ParseImagePath(value)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// setChartNamespaces puts the namespaced objects rendered from charts without a namespace into the
+// namespace of the release, as helm does when it installs the chart. Otherwise they would be
+// applied into the namespace of the kubectl context.
+func (c *Client) setChartNamespaces(manifest *v1alpha1.Manifest) error {
+	objects := map[int][]unstructured.Unstructured{}
+	all := []unstructured.Unstructured{}
+	for i, resource := range manifest.Spec.Resources {
+		resourceObjects, err := scan.ListObjectsFromContent([]byte(resource.Content))
+		if err != nil {
+			return fmt.Errorf("error scanning resource %s: %v", resource.Name, err)
+		}
+		objects[i] = resourceObjects
+		all = append(all, resourceObjects...)
+	}
+	crds := c.kindScopes(all)
+
+	for i, resourceObjects := range objects {
+		resource := &manifest.Spec.Resources[i]
+		if resource.Chart == nil {
+			continue
+		}
+		changed := false
+		docs := []string{}
+		for _, obj := range resourceObjects {
+			if obj.GetNamespace() == "" && isNamespaced(obj, crds) {
+				obj.SetNamespace(resource.Chart.ReleaseNamespace())
+				changed = true
+			}
+			content, err := yaml.Marshal(obj.Object)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, string(content))
+		}
+		if changed {
+			resource.Content = strings.Join(docs, "---\n")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+	log "github.com/sirupsen/logrus"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Diff writes a unified diff for every object of the installation whose live state in the cluster
+// differs from the manifest, and returns the number of such objects. Objects of a previously installed
+// manifest which are no longer part of the bundle are shown as removed.
+// Only the fields declared by the bundle are compared, fields defaulted or maintained by the cluster
+// are not reported as drift.
+func (c *Client) Diff(manifest *v1alpha1.Manifest, out io.Writer) (int, error) {
+	installed, err := c.installedManifest(manifest.Name)
+	if err != nil {
+		return 0, err
+	}
+	if installed != nil {
+		// an upgrade keeps the deferred resources installed on demand
+		manifest.Status.InstalledDeferred = keepInstalledDeferred(installed, manifest)
+	}
+	desired, err := manifestObjects(manifest)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := removedObjects(installed, desired)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i, obj := range append(desired, removed...) {
+		live, err := c.getLiveObject(obj)
+		if err != nil {
+			return 0, err
+		}
+		var before, after []byte
+		if live != nil {
+			before, err = yaml.Marshal(prune(live.Object, obj.Object))
+			if err != nil {
+				return 0, err
+			}
+		}
+		if i < len(desired) {
+			after, err = yaml.Marshal(obj.Object)
+			if err != nil {
+				return 0, err
+			}
+		}
+		text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(before)),
+			B:        difflib.SplitLines(string(after)),
+			FromFile: "live/" + objectName(obj),
+			ToFile:   "bundle/" + objectName(obj),
+			Context:  3,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if text != "" {
+			count++
+			_, err = fmt.Fprint(out, text)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return count, nil
+}
+
+func manifestObjects(manifest *v1alpha1.Manifest) ([]unstructured.Unstructured, error) {
+	objects := []unstructured.Unstructured{}
+	for _, resource := range manifest.Spec.Resources {
+		// deferred resources are only compared once installed, hooks and tests only exist while they run
+		if !manifest.Status.IsInstalled(resource) {
+			continue
+		}
+		objs, err := scan.ListObjectsFromContent([]byte(resource.Content))
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, objs...)
+	}
+	return objects, nil
+}
+
+// installedManifest returns the manifest of the installation, or nil when it is not installed
+func (c *Client) installedManifest(name string) (*v1alpha1.Manifest, error) {
+	installed, err := c.kabClient.ProjectriffV1alpha1().Manifests().Get(name, metav1.GetOptions{})
+	if err != nil {
+		if k8serr.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.New(fmt.Sprintf("unable to lookup manifest: %v", err))
+	}
+	err = c.readSecretContent(installed)
+	if err != nil {
+		return nil, err
+	}
+	return installed, nil
+}
+
+func removedObjects(installed *v1alpha1.Manifest, desired []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+	if installed == nil {
+		return nil, nil
+	}
+	installedObjects, err := manifestObjects(installed)
+	if err != nil {
+		return nil, err
+	}
+	removed := []unstructured.Unstructured{}
+	for _, obj := range installedObjects {
+		if !containsObject(desired, obj) {
+			removed = append(removed, obj)
+		}
+	}
+	return removed, nil
+}
+
+// getLiveObject returns the object as currently stored in the cluster, or nil when it does not exist
+func (c *Client) getLiveObject(obj unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	content, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.kubectl.ExecStdin([]string{"get", "-f", "-", "-o", "json", "--ignore-not-found"}, &content)
+	if err != nil {
+		if isUnverifiable(out) {
+			log.Debugf("%s cannot be looked up: %s", objectName(obj), out)
+			return nil, nil
+		}
+		return nil, errors.New(fmt.Sprintf("error looking up %s: %v, due to: %s", objectName(obj), err, out))
+	}
+	if strings.TrimSpace(out) == "" {
+		return nil, nil
+	}
+	live := &unstructured.Unstructured{}
+	err = json.Unmarshal([]byte(out), &live.Object)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error parsing %s: %v", objectName(obj), err))
+	}
+	return live, nil
+}
+
+// prune returns the parts of live which are declared in desired
+func prune(live interface{}, desired interface{}) interface{} {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return live
+		}
+		result := map[string]interface{}{}
+		for k, dv := range d {
+			if lv, found := l[k]; found {
+				result[k] = prune(lv, dv)
+			}
+		}
+		return result
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok {
+			return live
+		}
+		result := make([]interface{}, len(l))
+		for i := range l {
+			if i < len(d) {
+				result[i] = prune(l[i], d[i])
+			} else {
+				result[i] = l[i]
+			}
+		}
+		return result
+	default:
+		return live
+	}
+}
+
+func objectName(obj unstructured.Unstructured) string {
+	kind := strings.ToLower(obj.GetKind())
+	if obj.GetNamespace() == "" {
+		return fmt.Sprintf("%s/%s", kind, obj.GetName())
+	}
+	return fmt.Sprintf("%s/%s/%s", kind, obj.GetNamespace(), obj.GetName())
+}
+
+func sameObject(a unstructured.Unstructured, b unstructured.Unstructured) bool {
+	return a.GroupVersionKind().GroupKind() == b.GroupVersionKind().GroupKind() &&
+		a.GetNamespace() == b.GetNamespace() && a.GetName() == b.GetName()
+}
+
+func containsObject(objects []unstructured.Unstructured, obj unstructured.Unstructured) bool {
+	for _, o := range objects {
+		if sameObject(o, obj) {
+			return true
+		}
+	}
+	return false
+}
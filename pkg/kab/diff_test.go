@@ -0,0 +1,177 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/client/clientset/versioned/fake"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	mockkubectl "github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl/mocks"
+	"github.com/stretchr/testify/mock"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/testing"
+)
+
+var _ = Describe("Diff Tests", func() {
+
+	const deployment = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+  namespace: riff-system
+spec:
+  replicas: 1
+`
+	const configMap = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: riff-system
+data:
+  key: value
+`
+
+	var (
+		client        *kab.Client
+		fakeKabClient *fake.Clientset
+		mockKubectl   *mockkubectl.KubeCtl
+		manifest      *v1alpha1.Manifest
+		out           *bytes.Buffer
+		count         int
+		err           error
+	)
+
+	BeforeEach(func() {
+		fakeKabClient = fake.NewSimpleClientset()
+		mockKubectl = new(mockkubectl.KubeCtl)
+		out = &bytes.Buffer{}
+		manifest = &v1alpha1.Manifest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myInstall",
+			},
+			Spec: v1alpha1.KabSpec{
+				Resources: []v1alpha1.KabResource{
+					{
+						Name:    "res1",
+						Content: deployment,
+					},
+				},
+			},
+		}
+		client = kab.NewKnbClient(nil, nil, fakeKabClient, nil, mockKubectl)
+	})
+
+	Context("when the bundle is not installed", func() {
+		BeforeEach(func() {
+			fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				return true, nil, errors.NewNotFound(schema.GroupResource{}, "myInstall")
+			})
+		})
+
+		Context("when the object does not exist in the cluster", func() {
+			It("the whole object is reported", func() {
+				mockKubectl.On("ExecStdin", []string{"get", "-f", "-", "-o", "json", "--ignore-not-found"}, mock.Anything).Return("", nil)
+
+				count, err = client.Diff(manifest, out)
+				Expect(err).To(BeNil())
+				Expect(count).To(Equal(1))
+				Expect(out.String()).To(ContainSubstring("+++ bundle/deployment/riff-system/controller"))
+				Expect(out.String()).To(ContainSubstring("+kind: Deployment"))
+			})
+		})
+
+		Context("when the object has drifted", func() {
+			It("only the declared fields are compared", func() {
+				mockKubectl.On("ExecStdin", []string{"get", "-f", "-", "-o", "json", "--ignore-not-found"}, mock.Anything).Return(`{
+  "apiVersion": "apps/v1",
+  "kind": "Deployment",
+  "metadata": {"name": "controller", "namespace": "riff-system", "uid": "1234"},
+  "spec": {"replicas": 2, "revisionHistoryLimit": 10},
+  "status": {"replicas": 2}
+}`, nil)
+
+				count, err = client.Diff(manifest, out)
+				Expect(err).To(BeNil())
+				Expect(count).To(Equal(1))
+				Expect(out.String()).To(ContainSubstring("-  replicas: 2"))
+				Expect(out.String()).To(ContainSubstring("+  replicas: 1"))
+				Expect(out.String()).ToNot(ContainSubstring("uid"))
+				Expect(out.String()).ToNot(ContainSubstring("revisionHistoryLimit"))
+				Expect(out.String()).ToNot(ContainSubstring("status"))
+			})
+		})
+
+		Context("when the object matches the bundle", func() {
+			It("nothing is reported", func() {
+				mockKubectl.On("ExecStdin", []string{"get", "-f", "-", "-o", "json", "--ignore-not-found"}, mock.Anything).Return(`{
+  "apiVersion": "apps/v1",
+  "kind": "Deployment",
+  "metadata": {"name": "controller", "namespace": "riff-system", "uid": "1234"},
+  "spec": {"replicas": 1},
+  "status": {"replicas": 1}
+}`, nil)
+
+				count, err = client.Diff(manifest, out)
+				Expect(err).To(BeNil())
+				Expect(count).To(Equal(0))
+				Expect(out.String()).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("when an installed object is no longer part of the bundle", func() {
+		It("the object is reported as removed", func() {
+			installed := manifest.DeepCopy()
+			installed.Spec.Resources = append(installed.Spec.Resources, v1alpha1.KabResource{
+				Name:    "res2",
+				Content: configMap,
+			})
+			fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				return true, installed, nil
+			})
+			mockKubectl.On("ExecStdin", []string{"get", "-f", "-", "-o", "json", "--ignore-not-found"}, mock.MatchedBy(func(content *[]byte) bool {
+				return bytes.Contains(*content, []byte("Deployment"))
+			})).Return(`{
+  "apiVersion": "apps/v1",
+  "kind": "Deployment",
+  "metadata": {"name": "controller", "namespace": "riff-system"},
+  "spec": {"replicas": 1}
+}`, nil)
+			mockKubectl.On("ExecStdin", []string{"get", "-f", "-", "-o", "json", "--ignore-not-found"}, mock.Anything).Return(`{
+  "apiVersion": "v1",
+  "kind": "ConfigMap",
+  "metadata": {"name": "config", "namespace": "riff-system"},
+  "data": {"key": "value"}
+}`, nil)
+
+			count, err = client.Diff(manifest, out)
+			Expect(err).To(BeNil())
+			Expect(count).To(Equal(1))
+			Expect(out.String()).To(ContainSubstring("--- live/configmap/riff-system/config"))
+			Expect(out.String()).To(ContainSubstring("-kind: ConfigMap"))
+		})
+	})
+})
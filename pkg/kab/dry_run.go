@@ -0,0 +1,193 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const dryRunSuffix = "(server dry run)"
+
+// errors reported by a server-side dry-run for objects that depend on other objects of the
+// bundle (namespaces, CRDs) which are not persisted by the dry-run
+var unverifiablePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`namespaces? "[^"]*" not found`),
+	regexp.MustCompile(`no matches for kind`),
+	regexp.MustCompile(`ensure CRDs are installed first`),
+}
+
+// DryRunInstall submits every resource that Install would apply with server-side dry-run and
+// reports what would be created or changed. Neither the CRD nor the Manifest object are created.
+func (c *Client) DryRunInstall(manifest *v1alpha1.Manifest) error {
+	old, err := c.kabClient.ProjectriffV1alpha1().Manifests().Get(manifest.Name, metav1.GetOptions{})
+	if err != nil && !k8serr.IsNotFound(err) {
+		return errors.New(fmt.Sprintf("unable to lookup manifest: %v", err))
+	}
+	if err == nil && !isEmpty(old) {
+		return errors.New("bundle already installed")
+	}
+	log.Infof("Dry-run installing bundle components")
+	err = c.dryRunNamespaces(manifest)
+	if err != nil {
+		return err
+	}
+	err = c.dryRunResources(manifest)
+	if err != nil {
+		return err
+	}
+	actionEvent(EventActionDone, "install").WithField(DRY_RUN_FIELD, true).Infof("manifest %s would be created", manifest.Name)
+	return nil
+}
+
+// DryRunUpgrade submits every resource that Upgrade would apply with server-side dry-run and
+// reports what would be created or changed. The installation must already exist.
+func (c *Client) DryRunUpgrade(manifest *v1alpha1.Manifest) error {
+	old, err := c.LookupManifest(manifest.Name)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to lookup manifest: %v", err))
+	}
+	manifest.Status.InstalledDeferred = keepInstalledDeferred(old, manifest)
+	log.Infof("Dry-run upgrading bundle components")
+	err = c.dryRunNamespaces(manifest)
+	if err != nil {
+		return err
+	}
+	err = c.dryRunResources(manifest)
+	if err != nil {
+		return err
+	}
+	actionEvent(EventActionDone, "upgrade").WithField(DRY_RUN_FIELD, true).Infof("manifest %s would be updated", manifest.Name)
+	return nil
+}
+
+// DryRunUninstall reports the objects that Uninstall would delete, without deleting them.
+func (c *Client) DryRunUninstall(name string) error {
+	manifest, err := c.LookupManifest(name)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to lookup manifest: %v", err))
+	}
+	kindList, err := listKinds(manifest)
+	if err != nil {
+		return err
+	}
+	installationName := GetInstallationName()
+
+	log.Infof("dry-run uninstalling %s...", installationName)
+
+	out, err := c.kubectl.Exec(append(deleteArgs(kindList, installationName), "--dry-run=server"))
+	if err != nil {
+		return errors.New(fmt.Sprintf("error while uninstalling: %v, due to: %s", err, out))
+	}
+	reportDryRun(installationName, out)
+	if len(manifest.Status.CreatedNamespaces) > 0 {
+		deleted := []string{}
+		for _, namespace := range manifest.Status.CreatedNamespaces {
+			deleted = append(deleted, fmt.Sprintf("namespace/%s deleted", namespace))
+		}
+		reportDryRun("namespaces", strings.Join(deleted, "\n"))
+	}
+	actionEvent(EventActionDone, "uninstall").WithField(DRY_RUN_FIELD, true).Infof("manifest %s would be deleted", manifest.Name)
+	return nil
+}
+
+// dryRunNamespaces reports the declared namespaces which would be created or labeled
+func (c *Client) dryRunNamespaces(manifest *v1alpha1.Manifest) error {
+	if len(manifest.Spec.Namespaces) == 0 {
+		return nil
+	}
+	changes := []string{}
+	for _, declared := range manifest.Spec.Namespaces {
+		existing, err := c.coreClient.CoreV1().Namespaces().Get(declared.Name, metav1.GetOptions{})
+		switch {
+		case k8serr.IsNotFound(err):
+			changes = append(changes, fmt.Sprintf("namespace/%s created", declared.Name))
+		case err != nil:
+			return fmt.Errorf("could not look up namespace %s: %v", declared.Name, err)
+		case hasStrings(existing.Labels, declared.Labels) && hasStrings(existing.Annotations, declared.Annotations):
+			changes = append(changes, fmt.Sprintf("namespace/%s unchanged", declared.Name))
+		default:
+			changes = append(changes, fmt.Sprintf("namespace/%s configured", declared.Name))
+		}
+	}
+	reportDryRun("namespaces", strings.Join(changes, "\n"))
+	return nil
+}
+
+func (c *Client) dryRunResources(manifest *v1alpha1.Manifest) error {
+	rm := NewResourceManager(c.kubectl, c.coreClient)
+	for _, resource := range manifest.Spec.Resources {
+		if resource.Test || (resource.Deferred && !manifest.Status.IsInstalled(resource)) {
+			log.Debugf("Skipping dry-run of %s\n", resource.Name)
+			continue
+		}
+		out, err := rm.DryRun(resource)
+		if err != nil {
+			if !isUnverifiable(out) {
+				return errors.New(fmt.Sprintf("dry-run of %s failed: %v, due to: %s", resource.Name, err, out))
+			}
+			log.Warnf("some objects of %s cannot be verified until the objects they depend on exist:\n%s", resource.Name, out)
+			continue
+		}
+		reportDryRun(resource.Name, out)
+	}
+	return nil
+}
+
+func reportDryRun(name string, out string) {
+	log.Infof("%s:", name)
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), dryRunSuffix))
+		if line == "" {
+			continue
+		}
+		log.Infof("  %s", line)
+	}
+}
+
+// isUnverifiable returns true when every error of the output is caused by an object the bundle
+// creates, the results of the objects which passed the dry-run are ignored
+func isUnverifiable(out string) bool {
+	found := false
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasSuffix(line, dryRunSuffix) {
+			continue
+		}
+		if !matchesAny(line, unverifiablePatterns) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+func matchesAny(s string, patterns []*regexp.Regexp) bool {
+	for _, p := range patterns {
+		if p.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
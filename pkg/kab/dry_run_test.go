@@ -0,0 +1,204 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/client/clientset/versioned/fake"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab/vendor_mocks"
+	mockkubectl "github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl/mocks"
+	"github.com/stretchr/testify/mock"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/testing"
+)
+
+var _ = Describe("Dry-run Tests", func() {
+
+	var (
+		client           *kab.Client
+		mockKubeClient   *vendor_mocks.Interface
+		mockCore         *vendor_mocks.CoreV1Interface
+		mockNamespace    *vendor_mocks.NamespaceInterface
+		fakeKabClient    *fake.Clientset
+		mockKubectl      *mockkubectl.KubeCtl
+		manifest         *v1alpha1.Manifest
+		content          []byte
+		installationName string
+		err              error
+	)
+
+	BeforeEach(func() {
+		mockKubeClient = new(vendor_mocks.Interface)
+		mockCore = new(vendor_mocks.CoreV1Interface)
+		mockNamespace = new(vendor_mocks.NamespaceInterface)
+		fakeKabClient = fake.NewSimpleClientset()
+		mockKubectl = new(mockkubectl.KubeCtl)
+		mockKubeClient.On("CoreV1").Return(mockCore)
+		mockCore.On("Namespaces").Return(mockNamespace)
+		mockNamespace.On("List", mock.Anything).Return(&v12.NamespaceList{
+			Items: []v12.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "default"}}},
+		}, nil)
+
+		installationName = "myInstall"
+		content = []byte(`---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: knative-build
+`)
+		manifest = &v1alpha1.Manifest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: installationName,
+			},
+			Spec: v1alpha1.KabSpec{
+				Resources: []v1alpha1.KabResource{
+					{
+						Name:    "res1",
+						Content: string(content),
+					},
+					{
+						Name:     "deferred",
+						Deferred: true,
+					},
+				},
+			},
+		}
+
+		client = kab.NewKnbClient(mockKubeClient, nil, fakeKabClient, nil, mockKubectl)
+	})
+
+	JustBeforeEach(func() {
+		os.Setenv(kab.CNAB_INSTALLATION_NAME_ENV_VAR, installationName)
+	})
+
+	JustAfterEach(func() {
+		os.Unsetenv(kab.CNAB_INSTALLATION_NAME_ENV_VAR)
+		mockKubectl.AssertExpectations(GinkgoT())
+	})
+
+	Describe("DryRunInstall", func() {
+		Context("when the bundle is not installed", func() {
+			It("resources are applied with server-side dry-run and the manifest is not created", func() {
+				fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+					return true, nil, errors.NewNotFound(schema.GroupResource{}, installationName)
+				})
+				mockKubectl.On("ExecStdin", []string{"apply", "--dry-run=server", "-f", "-"}, &content).
+					Return("namespace/knative-build created (server dry run)", nil).Once()
+
+				err = client.DryRunInstall(manifest)
+				Expect(err).To(BeNil())
+				Expect(fakeKabClient.Actions()).To(HaveLen(1))
+				Expect(fakeKabClient.Actions()[0].GetVerb()).To(Equal("get"))
+			})
+		})
+
+		Context("when the bundle is already installed", func() {
+			It("an error is returned", func() {
+				fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+					return true, manifest, nil
+				})
+				err = client.DryRunInstall(manifest)
+				Expect(err).To(MatchError("bundle already installed"))
+			})
+		})
+
+		Context("when objects depend on namespaces created by the bundle", func() {
+			It("the dry-run continues", func() {
+				mockKubectl.On("ExecStdin", []string{"apply", "--dry-run=server", "-f", "-"}, &content).
+					Return(`Error from server (NotFound): error when creating "STDIN": namespaces "knative-build" not found`, errors.NewNotFound(schema.GroupResource{}, "")).Once()
+
+				err = client.DryRunInstall(manifest)
+				Expect(err).To(BeNil())
+			})
+		})
+
+		Context("when the namespace of the objects is created by the same resource", func() {
+			It("the dry-run continues", func() {
+				mockKubectl.On("ExecStdin", []string{"apply", "--dry-run=server", "-f", "-"}, &content).
+					Return("namespace/knative-build created (server dry run)\n"+
+						`Error from server (NotFound): error when creating "STDIN": namespaces "knative-build" not found`, errors.NewNotFound(schema.GroupResource{}, "")).Once()
+
+				err = client.DryRunInstall(manifest)
+				Expect(err).To(BeNil())
+			})
+		})
+
+		Context("when the api server rejects an object", func() {
+			It("an error is returned", func() {
+				mockKubectl.On("ExecStdin", []string{"apply", "--dry-run=server", "-f", "-"}, &content).
+					Return(`The Namespace "knative-build" is invalid`, errors.NewBadRequest("invalid")).Once()
+
+				err = client.DryRunInstall(manifest)
+				Expect(err).To(MatchError(HavePrefix("dry-run of res1 failed: invalid")))
+			})
+		})
+	})
+
+	Describe("DryRunUpgrade", func() {
+		Context("when the bundle is not installed", func() {
+			It("an error is returned", func() {
+				fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+					return true, nil, errors.NewNotFound(schema.GroupResource{}, installationName)
+				})
+				err = client.DryRunUpgrade(manifest)
+				Expect(err).To(MatchError("unable to lookup manifest: could not find manifest for installation name: myInstall"))
+			})
+		})
+
+		Context("when the bundle is installed", func() {
+			It("resources are applied with server-side dry-run and the manifest is not updated", func() {
+				fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+					return true, manifest.DeepCopy(), nil
+				})
+				mockKubectl.On("ExecStdin", []string{"apply", "--dry-run=server", "-f", "-"}, &content).
+					Return("namespace/knative-build unchanged (server dry run)", nil).Once()
+
+				err = client.DryRunUpgrade(manifest)
+				Expect(err).To(BeNil())
+				for _, action := range fakeKabClient.Actions() {
+					Expect(action.GetVerb()).To(Equal("get"))
+				}
+			})
+		})
+	})
+
+	Describe("DryRunUninstall", func() {
+		It("objects are deleted with server-side dry-run and the manifest is kept", func() {
+			fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				return true, manifest.DeepCopy(), nil
+			})
+			mockKubectl.On("Exec", []string{"delete", "Namespace", "-l",
+				kab.LABEL_KEY_NAME + "=" + installationName, "--dry-run=server"}).
+				Return("namespace \"knative-build\" deleted (server dry run)", nil).Once()
+
+			err = client.DryRunUninstall(installationName)
+			Expect(err).To(BeNil())
+			for _, action := range fakeKabClient.Actions() {
+				Expect(action.GetVerb()).To(Equal("get"))
+			}
+		})
+	})
+})
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"sync"
+	"time"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of the events recorded on the Manifest of an installation
+const (
+	ReasonInstallStarted     = "InstallStarted"
+	ReasonInstallCompleted   = "InstallCompleted"
+	ReasonInstallFailed      = "InstallFailed"
+	ReasonUpgradeStarted     = "UpgradeStarted"
+	ReasonUpgradeCompleted   = "UpgradeCompleted"
+	ReasonUpgradeFailed      = "UpgradeFailed"
+	ReasonUninstallStarted   = "UninstallStarted"
+	ReasonUninstallCompleted = "UninstallCompleted"
+	ReasonUninstallFailed    = "UninstallFailed"
+	ReasonResourceApplied    = "ResourceApplied"
+	ReasonResourceFailed     = "ResourceFailed"
+	ReasonCheckTimedOut      = "CheckTimedOut"
+	ReasonHookCompleted      = "HookCompleted"
+	ReasonHookFailed         = "HookFailed"
+	ReasonTestPassed         = "TestPassed"
+	ReasonTestFailed         = "TestFailed"
+	ReasonDeferredInstalled  = "DeferredInstalled"
+	ReasonDeferredFailed     = "DeferredFailed"
+
+	eventComponent = "kab-installer"
+)
+
+type eventRecorder struct {
+	watcher  watch.Interface
+	recorder record.EventRecorder
+	pending  sync.WaitGroup
+}
+
+// RecordEvents makes the client record Kubernetes Events on the Manifest of the installation it acts
+// on. FlushEvents must be called before exiting so that the events are not lost.
+func (c *Client) RecordEvents() {
+	c.recordEventsTo(func(event *corev1.Event) (*corev1.Event, error) {
+		return c.coreClient.CoreV1().Events(event.Namespace).Create(event)
+	})
+}
+
+func (c *Client) recordEventsTo(sink func(event *corev1.Event) (*corev1.Event, error)) {
+	r := &eventRecorder{}
+	broadcaster := record.NewBroadcaster()
+	r.watcher = broadcaster.StartEventWatcher(func(event *corev1.Event) {
+		defer r.pending.Done()
+		_, err := sink(event)
+		if err != nil {
+			log.Debugf("could not record event %s: %v", event.Reason, err)
+		}
+	})
+	r.recorder = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventComponent})
+	c.events = r
+}
+
+// FlushEvents waits up to timeout for the recorded events to be sent to the cluster
+func (c *Client) FlushEvents(timeout time.Duration) {
+	if c.events == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		c.events.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Debugln("timed out recording events")
+	}
+	c.events.watcher.Stop()
+}
+
+// event records an event on the manifest when recording is enabled
+func (c *Client) event(manifest *v1alpha1.Manifest, eventtype, reason, messageFmt string, args ...interface{}) {
+	if c.events == nil {
+		return
+	}
+	c.events.pending.Add(1)
+	c.events.recorder.Eventf(manifestReference(manifest), eventtype, reason, messageFmt, args...)
+}
+
+// manifestReference refers to the cluster scoped manifest. Events on cluster scoped objects are
+// stored in the default namespace.
+func manifestReference(manifest *v1alpha1.Manifest) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		APIVersion:      v1alpha1.SchemeGroupVersion.String(),
+		Kind:            "Manifest",
+		Name:            manifest.Name,
+		UID:             manifest.UID,
+		ResourceVersion: manifest.ResourceVersion,
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	mockkubectl "github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl/mocks"
+	"github.com/stretchr/testify/mock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Events", func() {
+	var (
+		mockKubeCtl *mockkubectl.KubeCtl
+		client      *Client
+		manifest    *v1alpha1.Manifest
+		lock        sync.Mutex
+		recorded    []*corev1.Event
+	)
+
+	reasons := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		r := []string{}
+		for _, event := range recorded {
+			r = append(r, event.Reason)
+		}
+		return r
+	}
+
+	BeforeEach(func() {
+		recorded = nil
+		mockKubeCtl = new(mockkubectl.KubeCtl)
+		client = NewKnbClient(nil, nil, nil, nil, mockKubeCtl)
+		manifest = &v1alpha1.Manifest{
+			ObjectMeta: metav1.ObjectMeta{Name: "riff", UID: "1234"},
+			Spec: v1alpha1.KabSpec{
+				Resources: []v1alpha1.KabResource{{Name: "res1", Content: "kind: Namespace"}},
+			},
+		}
+	})
+
+	Context("when events are recorded", func() {
+		BeforeEach(func() {
+			client.recordEventsTo(func(event *corev1.Event) (*corev1.Event, error) {
+				lock.Lock()
+				defer lock.Unlock()
+				recorded = append(recorded, event)
+				return event, nil
+			})
+		})
+
+		It("the events refer to the manifest", func() {
+			client.event(manifest, corev1.EventTypeNormal, ReasonInstallStarted, "Installing %d resources", 1)
+			client.FlushEvents(time.Second)
+
+			Expect(recorded).To(HaveLen(1))
+			event := recorded[0]
+			Expect(event.Namespace).To(Equal(metav1.NamespaceDefault))
+			Expect(event.InvolvedObject.Kind).To(Equal("Manifest"))
+			Expect(event.InvolvedObject.APIVersion).To(Equal("projectriff.io/v1alpha1"))
+			Expect(event.InvolvedObject.Name).To(Equal("riff"))
+			Expect(string(event.InvolvedObject.UID)).To(Equal("1234"))
+			Expect(event.Message).To(Equal("Installing 1 resources"))
+			Expect(event.Source.Component).To(Equal("kab-installer"))
+		})
+
+		It("applied resources are recorded", func() {
+			mockKubeCtl.On("ExecStdin", []string{"apply", "-f", "-"}, mock.Anything).Return("", nil)
+
+			err := client.installAndCheckResources(manifest)
+			Expect(err).To(BeNil())
+			client.FlushEvents(time.Second)
+			Expect(reasons()).To(Equal([]string{ReasonResourceApplied}))
+		})
+
+		It("failed checks are recorded", func() {
+			mockKubeCtl.On("ExecStdin", []string{"apply", "-f", "-"}, mock.Anything).Return("", nil)
+			manifest.Spec.Resources[0].Checks = []v1alpha1.ResourceChecks{{Kind: "Deployment"}}
+
+			err := client.installAndCheckResources(manifest)
+			Expect(err).To(MatchError("unknown resource kind: Deployment"))
+			client.FlushEvents(time.Second)
+			Expect(reasons()).To(ConsistOf(ReasonResourceApplied, ReasonResourceFailed))
+		})
+
+		It("a failing sink does not fail the action", func() {
+			client.recordEventsTo(func(event *corev1.Event) (*corev1.Event, error) {
+				return nil, errors.New("forbidden")
+			})
+			client.event(manifest, corev1.EventTypeWarning, ReasonInstallFailed, "Install failed")
+			client.FlushEvents(time.Second)
+		})
+	})
+
+	Context("when events are not recorded", func() {
+		It("no event is sent", func() {
+			client.event(manifest, corev1.EventTypeNormal, ReasonInstallStarted, "Installing")
+			client.FlushEvents(time.Second)
+			Expect(recorded).To(BeEmpty())
+		})
+	})
+})
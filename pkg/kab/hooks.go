@@ -0,0 +1,293 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+	log "github.com/sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// the jobs and pods of hooks and tests are awaited for up to awaitTimeout, their logs are followed
+// for up to logsGracePeriod more when they fail
+const (
+	awaitTimeout      = 10 * time.Minute
+	awaitPollInterval = 2 * time.Second
+	logsGracePeriod   = 10 * time.Second
+)
+
+// runHooks runs the resources of the manifest with the hook phase, in the order of the manifest.
+// The first hook which fails stops the action.
+func (c *Client) runHooks(manifest *v1alpha1.Manifest, phase string) error {
+	for _, resource := range manifest.Spec.Resources {
+		if resource.Hook != phase {
+			continue
+		}
+		err := c.runHook(resource)
+		if err != nil {
+			c.event(manifest, corev1.EventTypeWarning, ReasonHookFailed, "%s hook %s failed: %v", phase, resource.Name, err)
+			return fmt.Errorf("%s hook %s failed: %v", phase, resource.Name, err)
+		}
+		c.event(manifest, corev1.EventTypeNormal, ReasonHookCompleted, "%s hook %s completed", phase, resource.Name)
+	}
+	return nil
+}
+
+// runHook applies the resource, waits for its jobs and pods to complete while following their logs
+// to the installer output, and deletes the resource according to its deletion policy
+func (c *Client) runHook(resource v1alpha1.KabResource) error {
+	resourceEvent(EventHookStarted, resource.Name).WithField(HOOK_FIELD, resource.Hook).Infof("running %s hook %s...", resource.Hook, resource.Name)
+	workloads, err := hookWorkloads(resource, c.contextNamespace())
+	if err != nil {
+		return err
+	}
+	// jobs cannot be changed once created, the objects of a previous run are replaced
+	err = c.deleteHookObjects(resource)
+	if err != nil {
+		return err
+	}
+	rm := NewResourceManager(c.kubectl, c.coreClient)
+	err = rm.Install(resource, backOffSettings())
+	if err != nil {
+		return err
+	}
+
+	awaitErr := c.awaitWorkloads(resourceEvent(EventHookLog, resource.Name).WithField(HOOK_FIELD, resource.Hook), workloads)
+
+	policy := resource.DeletePolicy()
+	if policy == v1alpha1.HookDeleteAlways || (policy == v1alpha1.HookDeleteSucceeded && awaitErr == nil) {
+		err = c.deleteHookObjects(resource)
+		if err != nil && awaitErr == nil {
+			return err
+		}
+	}
+	if awaitErr != nil {
+		return awaitErr
+	}
+	resourceEvent(EventHookDone, resource.Name).WithField(HOOK_FIELD, resource.Hook).Infof("%s hook %s completed", resource.Hook, resource.Name)
+	return nil
+}
+
+// hookWorkloads returns the jobs and pods of the resource, which are awaited to complete. Those
+// without a namespace are applied by kubectl in the namespace of its context.
+func hookWorkloads(resource v1alpha1.KabResource, namespace string) ([]unstructured.Unstructured, error) {
+	objects, err := scan.ListObjectsFromContent([]byte(resource.Content))
+	if err != nil {
+		return nil, err
+	}
+	workloads := []unstructured.Unstructured{}
+	for _, obj := range objects {
+		if isJob(obj) || isPod(obj) {
+			if obj.GetNamespace() == "" {
+				obj.SetNamespace(namespace)
+			}
+			workloads = append(workloads, obj)
+		}
+	}
+	return workloads, nil
+}
+
+func isJob(obj unstructured.Unstructured) bool {
+	return obj.GetAPIVersion() == "batch/v1" && obj.GetKind() == "Job"
+}
+
+func isPod(obj unstructured.Unstructured) bool {
+	return obj.GetAPIVersion() == "v1" && obj.GetKind() == "Pod"
+}
+
+// awaitWorkloads waits for the jobs and pods among objects to complete, in order, while following
+// the logs of their pods with the fields of entry. The first which fails or times out is returned.
+func (c *Client) awaitWorkloads(entry *log.Entry, objects []unstructured.Unstructured) error {
+	for _, obj := range objects {
+		logs := &podLogs{entry: entry, followed: map[string]bool{}}
+		var err error
+		if isJob(obj) {
+			err = c.awaitJob(obj, logs)
+		} else if isPod(obj) {
+			err = c.awaitPod(obj.GetNamespace(), obj.GetName(), logs)
+		} else {
+			continue
+		}
+		logs.stop(err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) deleteHookObjects(resource v1alpha1.KabResource) error {
+	content := []byte(resource.Content)
+	out, err := c.kubectl.ExecStdin([]string{"delete", "--ignore-not-found", "-f", "-"}, &content)
+	log.Debugln(out)
+	if err != nil {
+		return fmt.Errorf("error deleting the objects of hook %s: %v, due to: %s", resource.Name, err, out)
+	}
+	return nil
+}
+
+// awaitJob waits until the job completes, or returns an error when it fails or times out. The logs
+// of its pods are followed once they start.
+func (c *Client) awaitJob(obj unstructured.Unstructured, logs *podLogs) error {
+	jobs := c.coreClient.BatchV1().Jobs(obj.GetNamespace())
+	err := wait.PollImmediate(awaitPollInterval, awaitTimeout, func() (bool, error) {
+		job, err := jobs.Get(obj.GetName(), metav1.GetOptions{})
+		if k8serr.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		c.followJobLogs(logs, obj)
+		for _, condition := range job.Status.Conditions {
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch condition.Type {
+			case batchv1.JobComplete:
+				return true, nil
+			case batchv1.JobFailed:
+				return false, fmt.Errorf("job %s/%s failed: %s", obj.GetNamespace(), obj.GetName(), condition.Message)
+			}
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return errors.New(fmt.Sprintf("timed out waiting for job %s/%s to complete", obj.GetNamespace(), obj.GetName()))
+	}
+	return err
+}
+
+// awaitPod waits until the pod succeeds, or returns an error when it fails or times out. Its logs
+// are followed once it starts.
+func (c *Client) awaitPod(namespace string, name string, logs *podLogs) error {
+	pods := c.coreClient.CoreV1().Pods(namespace)
+	err := wait.PollImmediate(awaitPollInterval, awaitTimeout, func() (bool, error) {
+		pod, err := pods.Get(name, metav1.GetOptions{})
+		if k8serr.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if pod.Status.Phase != corev1.PodPending {
+			c.followPodLogs(logs, namespace, name)
+		}
+		switch pod.Status.Phase {
+		case corev1.PodSucceeded:
+			return true, nil
+		case corev1.PodFailed:
+			return false, fmt.Errorf("pod %s/%s failed: %s", namespace, name, pod.Status.Message)
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return errors.New(fmt.Sprintf("timed out waiting for pod %s/%s to complete", namespace, name))
+	}
+	return err
+}
+
+// podLogs writes the logs of the pods it follows to the installer output, line by line, with the
+// fields of entry. Logs which cannot be read are reported without failing.
+type podLogs struct {
+	entry    *log.Entry
+	followed map[string]bool
+	streams  []io.Closer
+	stopped  bool
+	lock     sync.Mutex
+	done     sync.WaitGroup
+}
+
+// stop waits for the logs being followed to end. When err is set, the pods may still be running and
+// the logs are cut after logsGracePeriod.
+func (l *podLogs) stop(err error) {
+	ended := make(chan struct{})
+	go func() {
+		l.done.Wait()
+		close(ended)
+	}()
+	if err != nil {
+		select {
+		case <-ended:
+		case <-time.After(logsGracePeriod):
+		}
+	}
+	l.lock.Lock()
+	l.stopped = true
+	if err != nil {
+		for _, stream := range l.streams {
+			stream.Close()
+		}
+	}
+	l.lock.Unlock()
+	<-ended
+}
+
+// followJobLogs follows the logs of the pods of the job which started
+func (c *Client) followJobLogs(logs *podLogs, job unstructured.Unstructured) {
+	podList, err := c.coreClient.CoreV1().Pods(job.GetNamespace()).List(metav1.ListOptions{LabelSelector: "job-name=" + job.GetName()})
+	if err != nil {
+		log.Debugf("could not list the pods of job %s/%s: %v", job.GetNamespace(), job.GetName(), err)
+		return
+	}
+	for _, pod := range podList.Items {
+		if pod.Status.Phase != corev1.PodPending {
+			c.followPodLogs(logs, pod.Namespace, pod.Name)
+		}
+	}
+}
+
+// followPodLogs writes the logs of the pod as they are written, until its containers terminate
+func (c *Client) followPodLogs(logs *podLogs, namespace string, name string) {
+	logs.lock.Lock()
+	defer logs.lock.Unlock()
+	if logs.followed[name] || logs.stopped {
+		return
+	}
+	logs.followed[name] = true
+	logs.done.Add(1)
+	go func() {
+		defer logs.done.Done()
+		stream, err := c.coreClient.CoreV1().Pods(namespace).GetLogs(name, &corev1.PodLogOptions{Follow: true}).Stream()
+		if err != nil {
+			log.Warnf("could not read the logs of pod %s/%s: %v", namespace, name, err)
+			return
+		}
+		defer stream.Close()
+		logs.lock.Lock()
+		logs.streams = append(logs.streams, stream)
+		logs.lock.Unlock()
+		podLog := logs.entry.WithField(POD_FIELD, name)
+		scanner := bufio.NewScanner(stream)
+		for scanner.Scan() {
+			podLog.Info(scanner.Text())
+		}
+	}()
+}
@@ -0,0 +1,228 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/client/clientset/versioned/fake"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	mockkubectl "github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl/mocks"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	restfake "k8s.io/client-go/rest/fake"
+	"k8s.io/client-go/testing"
+)
+
+var _ = Describe("Hooks", func() {
+
+	const (
+		migrateJob = "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n  namespace: riff-system\n"
+		smokeJob   = "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: smoke\n"
+		app        = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n"
+		seedPod    = "apiVersion: v1\nkind: Pod\nmetadata:\n  name: seed\n"
+	)
+
+	var (
+		client         *kab.Client
+		fakeKubeClient *kubefake.Clientset
+		mockKubectl    *mockkubectl.KubeCtl
+		manifest       *v1alpha1.Manifest
+		migrateStatus  batchv1.JobStatus
+		namespace      string
+		logs           *bytes.Buffer
+		err            error
+	)
+
+	job := func(namespace string, name string, status batchv1.JobStatus) *batchv1.Job {
+		return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}, Status: status}
+	}
+	complete := batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: v1.ConditionTrue}}}
+
+	// kubectlCalls returns the kubectl command and the name of the resource of every call
+	kubectlCalls := func() []string {
+		calls := []string{}
+		for _, call := range mockKubectl.Calls {
+			content := string(*call.Arguments.Get(1).(*[]byte))
+			name := map[string]string{migrateJob: "migrate", smokeJob: "smoke", app: "app", seedPod: "seed"}[content]
+			calls = append(calls, call.Arguments.Get(0).([]string)[0]+" "+name)
+		}
+		return calls
+	}
+
+	BeforeEach(func() {
+		migrateStatus = complete
+		namespace = "default"
+		logs = &bytes.Buffer{}
+		log.SetOutput(logs)
+		manifest = &v1alpha1.Manifest{
+			ObjectMeta: metav1.ObjectMeta{Name: "test"},
+			Spec: v1alpha1.KabSpec{Resources: []v1alpha1.KabResource{
+				{Name: "migrate", Content: migrateJob, Hook: v1alpha1.HookPreUpgrade},
+				{Name: "app", Content: app},
+				{Name: "smoke", Content: smokeJob, Hook: v1alpha1.HookPostUpgrade, HookDeletePolicy: v1alpha1.HookDeleteNever},
+				{Name: "uninstall", Content: smokeJob, Hook: v1alpha1.HookPreUninstall},
+			}},
+		}
+		mockKubectl = new(mockkubectl.KubeCtl)
+		mockKubectl.On("ExecStdin", mock.Anything, mock.Anything).Return("", nil)
+	})
+
+	AfterEach(func() {
+		log.SetOutput(os.Stderr)
+	})
+
+	JustBeforeEach(func() {
+		fakeKubeClient = kubefake.NewSimpleClientset(
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+			job("riff-system", "migrate", migrateStatus),
+			job(namespace, "smoke", complete),
+			&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "riff-system", Name: "migrate-abcde", Labels: map[string]string{"job-name": "migrate"}}},
+			&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "seed"}, Status: v1.PodStatus{Phase: v1.PodSucceeded}},
+		)
+		fakeKabClient := fake.NewSimpleClientset()
+		fakeKabClient.PrependReactor("*", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+			return true, manifest.DeepCopy(), nil
+		})
+		client = kab.NewKnbClient(&logsClientset{Clientset: fakeKubeClient, logs: "migrating\ndone\n"}, nil, fakeKabClient, nil, mockKubectl)
+		client.SetNamespace(namespace)
+		err = client.Upgrade(manifest)
+	})
+
+	Context("when the hooks succeed", func() {
+		It("runs them around the resources and deletes them per their policy", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kubectlCalls()).To(Equal([]string{
+				"delete migrate",
+				"apply migrate",
+				"delete migrate",
+				"apply app",
+				"delete smoke",
+				"apply smoke",
+			}))
+		})
+
+		It("writes the logs of the jobs to the output", func() {
+			Expect(logs.String()).To(ContainSubstring(`msg=migrating event=hook_log hook=preUpgrade pod=migrate-abcde resource=migrate`))
+			Expect(logs.String()).To(ContainSubstring(`msg=done event=hook_log hook=preUpgrade pod=migrate-abcde resource=migrate`))
+			Expect(logs.String()).To(ContainSubstring("preUpgrade hook migrate completed"))
+		})
+	})
+
+	Context("when a hook is a pod", func() {
+		BeforeEach(func() {
+			manifest.Spec.Resources = append(manifest.Spec.Resources, v1alpha1.KabResource{Name: "seed", Content: seedPod, Hook: v1alpha1.HookPostUpgrade})
+		})
+
+		It("the pod is awaited and its logs written to the output", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kubectlCalls()).To(ContainElement("delete seed"))
+			Expect(logs.String()).To(ContainSubstring(`msg=migrating event=hook_log hook=postUpgrade pod=seed resource=seed`))
+			Expect(logs.String()).To(ContainSubstring("postUpgrade hook seed completed"))
+		})
+	})
+
+	Context("when the kubectl context has a namespace", func() {
+		BeforeEach(func() {
+			namespace = "riff-ci"
+			manifest.Spec.Resources = append(manifest.Spec.Resources, v1alpha1.KabResource{Name: "seed", Content: seedPod, Hook: v1alpha1.HookPostUpgrade})
+		})
+
+		It("the jobs and pods without a namespace are awaited there", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(logs.String()).To(ContainSubstring("postUpgrade hook smoke completed"))
+			Expect(logs.String()).To(ContainSubstring("postUpgrade hook seed completed"))
+		})
+	})
+
+	Context("when a hook job fails", func() {
+		BeforeEach(func() {
+			migrateStatus = batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: v1.ConditionTrue, Message: "Job has reached the specified backoff limit"},
+			}}
+		})
+
+		It("the action fails and the hook is kept", func() {
+			Expect(err).To(MatchError(ContainSubstring("preUpgrade hook migrate failed: job riff-system/migrate failed: Job has reached the specified backoff limit")))
+			Expect(kubectlCalls()).To(Equal([]string{"delete migrate", "apply migrate"}))
+		})
+
+		Context("and the hook is always deleted", func() {
+			BeforeEach(func() {
+				manifest.Spec.Resources[0].HookDeletePolicy = v1alpha1.HookDeleteAlways
+			})
+
+			It("the hook is deleted", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(kubectlCalls()).To(Equal([]string{"delete migrate", "apply migrate", "delete migrate"}))
+			})
+		})
+	})
+})
+
+// logsClientset serves the same logs for every pod, which the fake clientset does not support. The
+// logs are only served when followed, as they are while the pods run.
+type logsClientset struct {
+	*kubefake.Clientset
+	logs string
+}
+
+func (c *logsClientset) CoreV1() corev1client.CoreV1Interface {
+	return &logsCoreV1{CoreV1Interface: c.Clientset.CoreV1(), logs: c.logs}
+}
+
+type logsCoreV1 struct {
+	corev1client.CoreV1Interface
+	logs string
+}
+
+func (c *logsCoreV1) Pods(namespace string) corev1client.PodInterface {
+	return &logsPods{PodInterface: c.CoreV1Interface.Pods(namespace), logs: c.logs}
+}
+
+type logsPods struct {
+	corev1client.PodInterface
+	logs string
+}
+
+func (p *logsPods) GetLogs(name string, opts *v1.PodLogOptions) *rest.Request {
+	client := &restfake.RESTClient{
+		NegotiatedSerializer: scheme.Codecs,
+		Client: restfake.CreateHTTPClient(func(*http.Request) (*http.Response, error) {
+			if !opts.Follow {
+				return &http.Response{StatusCode: http.StatusBadRequest, Body: ioutil.NopCloser(strings.NewReader("logs are not followed"))}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(p.logs))}, nil
+		}),
+	}
+	return client.Get()
+}
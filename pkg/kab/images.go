@@ -0,0 +1,108 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+)
+
+// BundleImage is an entry of the images of a CNAB bundle, which are relocated with the bundle
+type BundleImage struct {
+	ImageType string `json:"imageType"`
+	Image     string `json:"image"`
+}
+
+var unsafeImageNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// ManifestImages returns the unique, sorted images of all the resources of the manifest. The
+// resource contents must be inlined.
+func ManifestImages(manifest *v1alpha1.Manifest, paths ...scan.ImagePath) ([]string, error) {
+	images := map[string]bool{}
+	for _, resource := range manifest.Spec.Resources {
+		resourceImages, err := scan.ListImagesFromContent([]byte(resource.Content), paths...)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning resource %s: %v", resource.Name, err)
+		}
+		for _, image := range resourceImages {
+			images[image] = true
+		}
+	}
+	list := []string{}
+	for image := range images {
+		list = append(list, image)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// BundleImages names every image after its repository, adding path segments of the repository
+// until the name is unique
+func BundleImages(images []string) map[string]BundleImage {
+	bundleImages := map[string]BundleImage{}
+	for _, image := range images {
+		segments := strings.Split(imageRepository(image), "/")
+		name := ""
+		for i := len(segments) - 1; i >= 0; i-- {
+			name = strings.Trim(unsafeImageNameChars.ReplaceAllString(strings.ToLower(strings.Join(segments[i:], "-")), "-"), "-")
+			if _, taken := bundleImages[name]; !taken {
+				break
+			}
+		}
+		if name == "" {
+			name = "image"
+		}
+		base := name
+		for i := 2; ; i++ {
+			if _, taken := bundleImages[name]; !taken {
+				break
+			}
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+		bundleImages[name] = BundleImage{ImageType: "docker", Image: image}
+	}
+	return bundleImages
+}
+
+// WriteBundleImages writes the images as the images section of a bundle, to be merged into
+// duffle.json or bundle.json
+func WriteBundleImages(out io.Writer, images []string) error {
+	content, err := json.MarshalIndent(map[string]interface{}{"images": BundleImages(images)}, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", content)
+	return err
+}
+
+// imageRepository strips the tag and digest of an image reference
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
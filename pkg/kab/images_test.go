@@ -0,0 +1,69 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+)
+
+var _ = Describe("Images Tests", func() {
+
+	It("lists the images of every resource once", func() {
+		manifest := &v1alpha1.Manifest{
+			Spec: v1alpha1.KabSpec{
+				Resources: []v1alpha1.KabResource{
+					{Name: "res1", Content: "kind: Pod\nspec:\n  containers:\n  - image: busybox:1.31\n"},
+					{Name: "res2", Content: "kind: Pod\nspec:\n  containers:\n  - image: busybox:1.31\n  - image: nginx\n"},
+					{Name: "res3", Content: "kind: Image\nspec:\n  image: example.com/custom:1\n"},
+				},
+			},
+		}
+		images, err := kab.ManifestImages(manifest, scan.ImagePath{Kind: "Image", JsonPath: ".spec.image"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(images).To(Equal([]string{"busybox:1.31", "example.com/custom:1", "nginx"}))
+	})
+
+	It("names the bundle images after their repositories", func() {
+		bundleImages := kab.BundleImages([]string{
+			"gcr.io/knative-releases/github.com/knative/build/cmd/controller@sha256:3981b1",
+			"gcr.io/knative-releases/github.com/knative/serving/cmd/controller@sha256:28db33",
+			"localhost:5000/busybox:1.31",
+			"busybox",
+		})
+		Expect(bundleImages).To(Equal(map[string]kab.BundleImage{
+			"controller":     {ImageType: "docker", Image: "gcr.io/knative-releases/github.com/knative/build/cmd/controller@sha256:3981b1"},
+			"cmd-controller": {ImageType: "docker", Image: "gcr.io/knative-releases/github.com/knative/serving/cmd/controller@sha256:28db33"},
+			"busybox":        {ImageType: "docker", Image: "localhost:5000/busybox:1.31"},
+			"busybox-2":      {ImageType: "docker", Image: "busybox"},
+		}))
+	})
+
+	It("writes the images section of a bundle", func() {
+		out := &bytes.Buffer{}
+		Expect(kab.WriteBundleImages(out, []string{"busybox:1.31"})).To(Succeed())
+		var bundle map[string]map[string]kab.BundleImage
+		Expect(json.Unmarshal(out.Bytes(), &bundle)).To(Succeed())
+		Expect(bundle["images"]).To(HaveKeyWithValue("busybox", kab.BundleImage{ImageType: "docker", Image: "busybox:1.31"}))
+	})
+})
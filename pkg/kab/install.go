@@ -22,12 +22,19 @@ import (
 
 	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 func (c *Client) Install(manifest *v1alpha1.Manifest) error {
+	if GetTargetNamespace() != "" {
+		err := c.checkClusterScopedObjects(manifest)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Could not install riff: %s ", err))
+		}
+	}
 	err := CreateCRD(c.extClient)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Could not create kab CRD: %s ", err))
@@ -38,11 +45,21 @@ func (c *Client) Install(manifest *v1alpha1.Manifest) error {
 	}
 	log.Infoln("Installing bundle components")
 	log.Infoln()
-	err = c.installAndCheckResources(manifest)
+	c.event(manifest, corev1.EventTypeNormal, ReasonInstallStarted, "Installing %d resources", len(manifest.Spec.Resources))
+	err = c.createNamespaces(manifest, manifest)
+	if err == nil {
+		err = c.installWithHooks(manifest, v1alpha1.HookPreInstall, v1alpha1.HookPostInstall)
+	}
 	if err != nil {
+		c.event(manifest, corev1.EventTypeWarning, ReasonInstallFailed, "Install failed: %v", err)
 		return errors.New(fmt.Sprintf("Could not install riff: %s ", err))
 	}
-	log.Infof("Kubernetes Application Bundle installed\n\n")
+	err = c.writeManifestOutputs(manifest, backOffSettings())
+	if err != nil {
+		return err
+	}
+	c.event(manifest, corev1.EventTypeNormal, ReasonInstallCompleted, "Installation completed")
+	actionEvent(EventActionDone, "install").Infof("Kubernetes Application Bundle installed")
 	return nil
 }
 
@@ -66,11 +83,15 @@ func (c *Client) CreateCRDObject(manifest *v1alpha1.Manifest, backOffSettings wa
 		if !isEmpty(old) {
 			return true, errors.New("bundle already installed")
 		}
-		_, err = c.kabClient.ProjectriffV1alpha1().Manifests().Create(manifest)
+		created, err := c.kabClient.ProjectriffV1alpha1().Manifests().Create(storedManifest(manifest))
 		if err != nil {
 			log.Debugln("error creating object", err)
 			return false, nil
 		}
+		if created != nil {
+			manifest.UID = created.UID
+			manifest.ResourceVersion = created.ResourceVersion
+		}
 		return true, nil
 	})
 	if err == wait.ErrWaitTimeout {
@@ -86,21 +107,51 @@ func isEmpty(manifest *v1alpha1.Manifest) bool {
 	return false
 }
 
+// installWithHooks installs the resources of the manifest between the hooks of the pre and post phases
+func (c *Client) installWithHooks(manifest *v1alpha1.Manifest, pre string, post string) error {
+	err := c.runHooks(manifest, pre)
+	if err != nil {
+		return err
+	}
+	err = c.installAndCheckResources(manifest)
+	if err != nil {
+		return err
+	}
+	return c.runHooks(manifest, post)
+}
+
 func (c *Client) installAndCheckResources(manifest *v1alpha1.Manifest) error {
 	rm := NewResourceManager(c.kubectl, c.coreClient)
 	for _, resource := range manifest.Spec.Resources {
-		if resource.Deferred {
-			log.Debugf("Skipping install of %s\n", resource.Name)
+		if !manifest.Status.IsInstalled(resource) {
+			if resource.Deferred {
+				log.Debugf("Skipping install of %s\n", resource.Name)
+			}
 			continue
 		}
-		err := rm.Install(resource, backOffSettings())
+		err := c.installAndCheckResource(rm, manifest, resource)
 		if err != nil {
 			return err
 		}
-		err = rm.Check(resource, backOffSettings())
-		if err != nil {
-			return err
+	}
+	return nil
+}
+
+func (c *Client) installAndCheckResource(rm *rm, manifest *v1alpha1.Manifest, resource v1alpha1.KabResource) error {
+	err := rm.Install(resource, backOffSettings())
+	if err != nil {
+		c.event(manifest, corev1.EventTypeWarning, ReasonResourceFailed, "Could not apply %s: %v", resource.Name, err)
+		return err
+	}
+	c.event(manifest, corev1.EventTypeNormal, ReasonResourceApplied, "Applied %s", resource.Name)
+	err = rm.Check(resource, backOffSettings())
+	if err != nil {
+		if _, ok := err.(*checkTimeoutError); ok {
+			c.event(manifest, corev1.EventTypeWarning, ReasonCheckTimedOut, "Checks of %s timed out", resource.Name)
+		} else {
+			c.event(manifest, corev1.EventTypeWarning, ReasonResourceFailed, "Checks of %s failed: %v", resource.Name, err)
 		}
+		return err
 	}
 	return nil
 }
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// InstallDeferred installs and checks the named deferred resources of an installation, and records
+// them in the status of its manifest so that upgrades keep them up to date. Resources which are
+// already installed are applied again.
+func (c *Client) InstallDeferred(name string, resources []string) error {
+	if len(resources) == 0 {
+		return errors.New("no deferred resources to install")
+	}
+	manifest, err := c.LookupManifest(name)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to lookup manifest: %v", err))
+	}
+	deferred, err := deferredResources(manifest, resources)
+	if err != nil {
+		return err
+	}
+	log.Infoln("Installing deferred bundle components")
+	log.Infoln()
+	rm := NewResourceManager(c.kubectl, c.coreClient)
+	for _, resource := range deferred {
+		err = c.installAndCheckResource(rm, manifest, resource)
+		if err != nil {
+			c.event(manifest, corev1.EventTypeWarning, ReasonDeferredFailed, "Install of %s failed: %v", resource.Name, err)
+			return errors.New(fmt.Sprintf("Could not install %s: %s ", resource.Name, err))
+		}
+		if !manifest.Status.IsInstalled(resource) {
+			manifest.Status.InstalledDeferred = append(manifest.Status.InstalledDeferred, resource.Name)
+		}
+	}
+	_, err = c.kabClient.ProjectriffV1alpha1().Manifests().Update(storedManifest(manifest))
+	if err != nil {
+		c.event(manifest, corev1.EventTypeWarning, ReasonDeferredFailed, "Could not update the manifest: %v", err)
+		return errors.New(fmt.Sprintf("error while updating the manifest: %v", err))
+	}
+	c.event(manifest, corev1.EventTypeNormal, ReasonDeferredInstalled, "Installed %s", strings.Join(resources, ", "))
+	actionEvent(EventActionDone, "install-deferred").Infof("deferred resources %s installed", strings.Join(resources, ", "))
+	return nil
+}
+
+// deferredResources returns the named resources of the manifest, in the order of the manifest
+func deferredResources(manifest *v1alpha1.Manifest, names []string) ([]v1alpha1.KabResource, error) {
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	deferred := []v1alpha1.KabResource{}
+	for _, resource := range manifest.Spec.Resources {
+		if !wanted[resource.Name] {
+			continue
+		}
+		if !resource.Deferred {
+			return nil, fmt.Errorf("resource %s of installation %s is not deferred", resource.Name, manifest.Name)
+		}
+		deferred = append(deferred, resource)
+		delete(wanted, resource.Name)
+	}
+	for _, name := range names {
+		if wanted[name] {
+			return nil, fmt.Errorf("installation %s has no resource %s", manifest.Name, name)
+		}
+	}
+	return deferred, nil
+}
+
+// keepInstalledDeferred returns the deferred resources installed on demand in the old manifest
+// which are still deferred in the new manifest
+func keepInstalledDeferred(old *v1alpha1.Manifest, manifest *v1alpha1.Manifest) []string {
+	var installed []string
+	for _, resource := range manifest.Spec.Resources {
+		if resource.Deferred && old.Status.IsInstalled(resource) {
+			installed = append(installed, resource.Name)
+		}
+	}
+	return installed
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/client/clientset/versioned/fake"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	mockkubectl "github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl/mocks"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/testing"
+)
+
+var _ = Describe("InstallDeferred", func() {
+
+	var (
+		client      *kab.Client
+		mockKubectl *mockkubectl.KubeCtl
+		manifest    *v1alpha1.Manifest
+		updated     *v1alpha1.Manifest
+		err         error
+	)
+
+	BeforeEach(func() {
+		updated = nil
+		manifest = &v1alpha1.Manifest{
+			ObjectMeta: metav1.ObjectMeta{Name: "riff"},
+			Spec: v1alpha1.KabSpec{Resources: []v1alpha1.KabResource{
+				{Name: "core", Content: "core content"},
+				{Name: "monitoring", Content: "monitoring content", Deferred: true},
+				{Name: "tracing", Content: "tracing content", Deferred: true},
+			}},
+		}
+		mockKubectl = new(mockkubectl.KubeCtl)
+		mockKubectl.On("ExecStdin", []string{"apply", "-f", "-"}, mock.Anything).Return("configured", nil)
+
+		fakeKabClient := fake.NewSimpleClientset()
+		fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+			return true, manifest.DeepCopy(), nil
+		})
+		fakeKabClient.PrependReactor("update", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+			updated = action.(testing.UpdateAction).GetObject().(*v1alpha1.Manifest)
+			return true, updated, nil
+		})
+		fakeKubeClient := kubefake.NewSimpleClientset(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+		client = kab.NewKnbClient(fakeKubeClient, nil, fakeKabClient, nil, mockKubectl)
+	})
+
+	appliedContents := func() []string {
+		contents := []string{}
+		for _, call := range mockKubectl.Calls {
+			contents = append(contents, string(*call.Arguments.Get(1).(*[]byte)))
+		}
+		return contents
+	}
+
+	It("installs the named resources in the order of the manifest and records them", func() {
+		err = client.InstallDeferred("riff", []string{"tracing", "monitoring"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(appliedContents()).To(Equal([]string{"monitoring content", "tracing content"}))
+		Expect(updated).NotTo(BeNil())
+		Expect(updated.Status.InstalledDeferred).To(Equal([]string{"monitoring", "tracing"}))
+	})
+
+	It("applies a resource installed before again without recording it twice", func() {
+		manifest.Status.InstalledDeferred = []string{"monitoring"}
+		err = client.InstallDeferred("riff", []string{"monitoring"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(appliedContents()).To(Equal([]string{"monitoring content"}))
+		Expect(updated.Status.InstalledDeferred).To(Equal([]string{"monitoring"}))
+	})
+
+	It("rejects a resource which is not deferred", func() {
+		err = client.InstallDeferred("riff", []string{"monitoring", "core"})
+		Expect(err).To(MatchError("resource core of installation riff is not deferred"))
+		Expect(mockKubectl.Calls).To(BeEmpty())
+		Expect(updated).To(BeNil())
+	})
+
+	It("rejects an unknown resource", func() {
+		err = client.InstallDeferred("riff", []string{"logging"})
+		Expect(err).To(MatchError("installation riff has no resource logging"))
+		Expect(mockKubectl.Calls).To(BeEmpty())
+	})
+
+	It("requires a resource", func() {
+		err = client.InstallDeferred("riff", nil)
+		Expect(err).To(MatchError("no deferred resources to install"))
+	})
+})
@@ -23,6 +23,7 @@ import (
 	"github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl"
 	"github.com/projectriff/cnab-k8s-installer-base/pkg/kustomize"
 	apiext "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -38,6 +39,8 @@ type Client struct {
 	kabClient  versioned.Interface
 	kustomizer kustomize.Kustomizer
 	kubectl    kubectl.KubeCtl
+	events     *eventRecorder
+	namespace  string
 }
 
 func NewKnbClient(core kubernetes.Interface, ext apiext.Interface, kab versioned.Interface, kustomizer kustomize.Kustomizer, kubectl kubectl.KubeCtl) *Client {
@@ -49,3 +52,18 @@ func NewKnbClient(core kubernetes.Interface, ext apiext.Interface, kab versioned
 		kubectl:    kubectl,
 	}
 }
+
+// SetNamespace sets the namespace of the kubectl context, which kubectl applies the namespaced
+// objects without a namespace into
+func (c *Client) SetNamespace(namespace string) {
+	c.namespace = namespace
+}
+
+// contextNamespace returns the namespace kubectl applies the namespaced objects without a namespace
+// into, default when it was not set
+func (c *Client) contextNamespace() string {
+	if c.namespace == "" {
+		return metav1.NamespaceDefault
+	}
+	return c.namespace
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"fmt"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// createNamespaces creates the namespaces declared by the manifest which do not exist yet, labeled
+// with the installation name, and records them in the status of the stored manifest right away so
+// that an uninstall deletes them even when the action fails later on. Only the status of stored is
+// updated, the manifest being installed is stored once the action succeeds. Namespaces which
+// already exist are given the declared labels and annotations.
+func (c *Client) createNamespaces(manifest *v1alpha1.Manifest, stored *v1alpha1.Manifest) error {
+	created := len(manifest.Status.CreatedNamespaces)
+	for _, declared := range manifest.Spec.Namespaces {
+		namespaces := c.coreClient.CoreV1().Namespaces()
+		existing, err := namespaces.Get(declared.Name, metav1.GetOptions{})
+		if k8serr.IsNotFound(err) {
+			obj := namespaceObject(declared)
+			_, err = namespaces.Create(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        obj.GetName(),
+				Labels:      obj.GetLabels(),
+				Annotations: obj.GetAnnotations(),
+			}})
+			if err != nil {
+				return fmt.Errorf("could not create namespace %s: %v", declared.Name, err)
+			}
+			log.Infof("created namespace %s", declared.Name)
+			if !containsString(manifest.Status.CreatedNamespaces, declared.Name) {
+				manifest.Status.CreatedNamespaces = append(manifest.Status.CreatedNamespaces, declared.Name)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not look up namespace %s: %v", declared.Name, err)
+		}
+		if hasStrings(existing.Labels, declared.Labels) && hasStrings(existing.Annotations, declared.Annotations) {
+			continue
+		}
+		existing.Labels = mergeStrings(existing.Labels, declared.Labels)
+		existing.Annotations = mergeStrings(existing.Annotations, declared.Annotations)
+		_, err = namespaces.Update(existing)
+		if err != nil {
+			return fmt.Errorf("could not update namespace %s: %v", declared.Name, err)
+		}
+		log.Infof("updated the labels and annotations of namespace %s", declared.Name)
+	}
+	if len(manifest.Status.CreatedNamespaces) == created {
+		return nil
+	}
+	stored.Status.CreatedNamespaces = manifest.Status.CreatedNamespaces
+	updated, err := c.kabClient.ProjectriffV1alpha1().Manifests().Update(storedManifest(stored))
+	if err != nil {
+		return fmt.Errorf("could not record the created namespaces: %v", err)
+	}
+	if updated != nil {
+		stored.ResourceVersion = updated.ResourceVersion
+		manifest.ResourceVersion = updated.ResourceVersion
+	}
+	return nil
+}
+
+// deleteNamespaces deletes the namespaces created by the installer, in the reverse order of their
+// creation
+func (c *Client) deleteNamespaces(manifest *v1alpha1.Manifest) error {
+	created := manifest.Status.CreatedNamespaces
+	for i := len(created) - 1; i >= 0; i-- {
+		err := c.coreClient.CoreV1().Namespaces().Delete(created[i], &metav1.DeleteOptions{})
+		if err != nil && !k8serr.IsNotFound(err) {
+			return fmt.Errorf("could not delete namespace %s: %v", created[i], err)
+		}
+		log.Infof("deleted namespace %s", created[i])
+	}
+	return nil
+}
+
+// namespaceObject returns the declared namespace as it is created by an install
+func namespaceObject(declared v1alpha1.KabNamespace) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("Namespace")
+	obj.SetName(declared.Name)
+	obj.SetLabels(mergeStrings(map[string]string{LABEL_KEY_NAME: GetInstallationName()}, declared.Labels))
+	if len(declared.Annotations) > 0 {
+		obj.SetAnnotations(declared.Annotations)
+	}
+	return obj
+}
+
+// mergeStrings returns a copy of base with the entries of overlay set
+func mergeStrings(base map[string]string, overlay map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		merged[k] = v
+	}
+	return merged
+}
+
+func hasStrings(m map[string]string, entries map[string]string) bool {
+	for k, v := range entries {
+		if value, ok := m[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	"errors"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/client/clientset/versioned/fake"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	mockkubectl "github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl/mocks"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/testing"
+)
+
+var _ = Describe("Namespaces", func() {
+
+	var (
+		client         *kab.Client
+		fakeKubeClient *kubefake.Clientset
+		mockKubectl    *mockkubectl.KubeCtl
+		installed      *v1alpha1.Manifest
+		manifest       *v1alpha1.Manifest
+		updates        []*v1alpha1.Manifest
+		err            error
+	)
+
+	BeforeEach(func() {
+		os.Setenv(kab.CNAB_INSTALLATION_NAME_ENV_VAR, "riff")
+		updates = nil
+		manifest = &v1alpha1.Manifest{
+			ObjectMeta: metav1.ObjectMeta{Name: "riff"},
+			Spec: v1alpha1.KabSpec{
+				Namespaces: []v1alpha1.KabNamespace{
+					{Name: "riff-system", Labels: map[string]string{"istio-injection": "enabled"}},
+					{Name: "knative-serving", Labels: map[string]string{"istio-injection": "enabled"}, Annotations: map[string]string{"owner": "riff"}},
+				},
+				Resources: []v1alpha1.KabResource{
+					{Name: "config", Content: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n  namespace: riff-system\n"},
+				},
+			},
+		}
+		installed = manifest.DeepCopy()
+		installed.Status.CreatedNamespaces = []string{"riff-system"}
+		mockKubectl = new(mockkubectl.KubeCtl)
+		mockKubectl.On("ExecStdin", mock.Anything, mock.Anything).Return("", nil)
+		mockKubectl.On("Exec", mock.Anything).Return("", nil)
+
+		fakeKubeClient = kubefake.NewSimpleClientset(
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "riff-system", Labels: map[string]string{"team": "riff"}}},
+		)
+		fakeKabClient := fake.NewSimpleClientset()
+		fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+			return true, installed.DeepCopy(), nil
+		})
+		fakeKabClient.PrependReactor("update", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+			updated := action.(testing.UpdateAction).GetObject().(*v1alpha1.Manifest).DeepCopy()
+			updates = append(updates, updated)
+			return true, updated, nil
+		})
+		fakeKabClient.PrependReactor("delete", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+			return true, nil, nil
+		})
+		client = kab.NewKnbClient(fakeKubeClient, nil, fakeKabClient, nil, mockKubectl)
+	})
+
+	AfterEach(func() {
+		os.Unsetenv(kab.CNAB_INSTALLATION_NAME_ENV_VAR)
+	})
+
+	getNamespace := func(name string) (*v1.Namespace, error) {
+		return fakeKubeClient.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+	}
+
+	Context("when upgrading", func() {
+		JustBeforeEach(func() {
+			err = client.Upgrade(manifest)
+		})
+
+		It("creates the missing namespaces labeled with the installation", func() {
+			Expect(err).NotTo(HaveOccurred())
+			namespace, err := getNamespace("knative-serving")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(namespace.Labels).To(Equal(map[string]string{kab.LABEL_KEY_NAME: "riff", "istio-injection": "enabled"}))
+			Expect(namespace.Annotations).To(Equal(map[string]string{"owner": "riff"}))
+		})
+
+		It("labels the existing namespaces", func() {
+			Expect(err).NotTo(HaveOccurred())
+			namespace, err := getNamespace("riff-system")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(namespace.Labels).To(Equal(map[string]string{"team": "riff", "istio-injection": "enabled"}))
+		})
+
+		It("records the created namespaces before the resources are installed", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updates).To(HaveLen(2))
+			Expect(updates[0].Status.CreatedNamespaces).To(Equal([]string{"riff-system", "knative-serving"}))
+			Expect(updates[1].Status.CreatedNamespaces).To(Equal([]string{"riff-system", "knative-serving"}))
+		})
+
+		Context("and the resources fail to install", func() {
+			BeforeEach(func() {
+				manifest.Spec.Resources = append(manifest.Spec.Resources, v1alpha1.KabResource{Name: "app", Content: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n"})
+				mockKubectl.ExpectedCalls = nil
+				mockKubectl.On("ExecStdin", mock.Anything, mock.Anything).Return("forbidden", errors.New("exit status 1"))
+			})
+
+			It("records the created namespaces on the installed manifest only", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(updates).To(HaveLen(1))
+				Expect(updates[0].Spec).To(Equal(installed.Spec))
+				Expect(updates[0].Status.CreatedNamespaces).To(Equal([]string{"riff-system", "knative-serving"}))
+			})
+		})
+	})
+
+	Context("when uninstalling", func() {
+		BeforeEach(func() {
+			installed.Status.CreatedNamespaces = []string{"riff-system"}
+		})
+
+		It("deletes only the namespaces it created, after the other objects", func() {
+			err = client.Uninstall("riff")
+			Expect(err).NotTo(HaveOccurred())
+			mockKubectl.AssertCalled(GinkgoT(), "Exec", []string{"delete", "ConfigMap", "-l", kab.LABEL_KEY_NAME + "=riff"})
+			_, err = getNamespace("riff-system")
+			Expect(k8serr.IsNotFound(err)).To(BeTrue())
+			_, err = getNamespace("default")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const standardOutputsDir = "/cnab/app/outputs"
+
+var outputsDir = standardOutputsDir
+
+// WriteOutput writes the named CNAB output. The CNAB runtime mounts the outputs directory, when
+// it is not present (e.g. when running outside of an invocation image) the output is skipped.
+func WriteOutput(name string, content []byte) error {
+	if _, err := os.Stat(outputsDir); os.IsNotExist(err) {
+		log.Debugf("outputs directory %s does not exist, skipping output %s", outputsDir, name)
+		return nil
+	}
+	path := filepath.Join(outputsDir, name)
+	err := ioutil.WriteFile(path, content, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write output %s: %v", path, err)
+	}
+	return nil
+}
+
+// writeManifestOutputs resolves the outputs declared by the manifest from the cluster and writes
+// them as CNAB outputs. The resources are already installed at this point, so an output which
+// cannot be resolved is reported and skipped rather than failing the action.
+func (c *Client) writeManifestOutputs(manifest *v1alpha1.Manifest, backOffSettings wait.Backoff) error {
+	for _, output := range manifest.Spec.Outputs {
+		value, err := c.resolveOutput(output, backOffSettings)
+		if err != nil {
+			log.Warnf("could not resolve output %s: %v", output.Name, err)
+			continue
+		}
+		err = WriteOutput(output.Name, []byte(value))
+		if err != nil {
+			return err
+		}
+		log.Debugf("wrote output %s", output.Name)
+	}
+	return nil
+}
+
+// resolveOutput waits until the output has a value, e.g. until a load balancer is assigned an address
+func (c *Client) resolveOutput(output v1alpha1.KabOutput, backOffSettings wait.Backoff) (string, error) {
+	var value string
+	var lastErr error
+	err := wait.ExponentialBackoff(backOffSettings, func() (bool, error) {
+		value, lastErr = c.readOutput(output)
+		return lastErr == nil && value != "", nil
+	})
+	if err == wait.ErrWaitTimeout {
+		if lastErr != nil {
+			return "", lastErr
+		}
+		return "", errors.New(fmt.Sprintf("%s %s has no value", output.Kind, output.ObjectName))
+	}
+	return value, err
+}
+
+func (c *Client) readOutput(output v1alpha1.KabOutput) (string, error) {
+	args := []string{"get", output.Kind, output.ObjectName}
+	if output.Namespace != "" {
+		args = append(args, "-n", output.Namespace)
+	}
+	if output.JsonPath != "" {
+		out, err := c.kubectl.Exec(append(args, "-o", "jsonpath="+output.JsonPath))
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("%v: %s", err, out))
+		}
+		return out, nil
+	}
+
+	out, err := c.kubectl.Exec(append(args, "-o", "json"))
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("%v: %s", err, out))
+	}
+	obj := struct {
+		Data map[string]string `json:"data"`
+	}{}
+	err = json.Unmarshal([]byte(out), &obj)
+	if err != nil {
+		return "", err
+	}
+	value := obj.Data[output.Key]
+	if value == "" || !strings.EqualFold(output.Kind, "Secret") {
+		return value, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("could not decode key %s: %v", output.Key, err))
+	}
+	return string(decoded), nil
+}
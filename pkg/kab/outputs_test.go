@@ -0,0 +1,153 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	mockkubectl "github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl/mocks"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/test_support"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var _ = Describe("WriteOutput", func() {
+	var (
+		tempDir string
+		err     error
+	)
+
+	BeforeEach(func() {
+		tempDir = test_support.CreateTempDir()
+	})
+
+	AfterEach(func() {
+		outputsDir = standardOutputsDir
+		test_support.CleanupDirs(GinkgoT(), tempDir)
+	})
+
+	Context("when the outputs directory exists", func() {
+		It("the output is written", func() {
+			outputsDir = tempDir
+			err = WriteOutput("diff", []byte("some diff"))
+			Expect(err).To(BeNil())
+			content, err := ioutil.ReadFile(filepath.Join(tempDir, "diff"))
+			Expect(err).To(BeNil())
+			Expect(string(content)).To(Equal("some diff"))
+		})
+	})
+
+	Context("when the outputs directory does not exist", func() {
+		It("the output is skipped", func() {
+			outputsDir = filepath.Join(tempDir, "nosuchdir")
+			err = WriteOutput("diff", []byte("some diff"))
+			Expect(err).To(BeNil())
+			_, err = os.Stat(outputsDir)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("Manifest outputs", func() {
+	var (
+		tempDir     string
+		mockKubeCtl *mockkubectl.KubeCtl
+		client      *Client
+		manifest    *v1alpha1.Manifest
+		err         error
+	)
+
+	readOutput := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(tempDir, name))
+		Expect(err).To(BeNil())
+		return string(content)
+	}
+
+	BeforeEach(func() {
+		tempDir = test_support.CreateTempDir()
+		outputsDir = tempDir
+		mockKubeCtl = new(mockkubectl.KubeCtl)
+		client = NewKnbClient(nil, nil, nil, nil, mockKubeCtl)
+		manifest = &v1alpha1.Manifest{}
+	})
+
+	AfterEach(func() {
+		outputsDir = standardOutputsDir
+		test_support.CleanupDirs(GinkgoT(), tempDir)
+		mockKubeCtl.AssertExpectations(GinkgoT())
+	})
+
+	Context("when an output uses a jsonpath", func() {
+		It("the value is written once it is available", func() {
+			manifest.Spec.Outputs = []v1alpha1.KabOutput{{
+				Name:       "ingress_ip",
+				Kind:       "Service",
+				Namespace:  "istio-system",
+				ObjectName: "istio-ingressgateway",
+				JsonPath:   "{.status.loadBalancer.ingress[0].ip}",
+			}}
+			args := []string{"get", "Service", "istio-ingressgateway", "-n", "istio-system", "-o", "jsonpath={.status.loadBalancer.ingress[0].ip}"}
+			mockKubeCtl.On("Exec", args).Return("", nil).Once()
+			mockKubeCtl.On("Exec", args).Return("10.0.0.1", nil).Once()
+
+			err = client.writeManifestOutputs(manifest, wait.Backoff{Steps: 2})
+			Expect(err).To(BeNil())
+			Expect(readOutput("ingress_ip")).To(Equal("10.0.0.1"))
+		})
+	})
+
+	Context("when an output uses a secret key", func() {
+		It("the decoded value is written", func() {
+			manifest.Spec.Outputs = []v1alpha1.KabOutput{{
+				Name:       "token",
+				Kind:       "Secret",
+				Namespace:  "riff-system",
+				ObjectName: "riff-token",
+				Key:        "token",
+			}}
+			mockKubeCtl.On("Exec", []string{"get", "Secret", "riff-token", "-n", "riff-system", "-o", "json"}).
+				Return(`{"kind": "Secret", "data": {"token": "c2VjcmV0"}}`, nil)
+
+			err = client.writeManifestOutputs(manifest, wait.Backoff{Steps: 2})
+			Expect(err).To(BeNil())
+			Expect(readOutput("token")).To(Equal("secret"))
+		})
+	})
+
+	Context("when an output cannot be resolved", func() {
+		It("the output is skipped", func() {
+			manifest.Spec.Outputs = []v1alpha1.KabOutput{{
+				Name:       "config",
+				Kind:       "ConfigMap",
+				ObjectName: "riff-config",
+				Key:        "url",
+			}}
+			mockKubeCtl.On("Exec", []string{"get", "ConfigMap", "riff-config", "-o", "json"}).
+				Return("not found", errors.New("exit status 1"))
+
+			err = client.writeManifestOutputs(manifest, wait.Backoff{Steps: 2})
+			Expect(err).To(BeNil())
+			_, err = os.Stat(filepath.Join(tempDir, "config"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+})
@@ -55,8 +55,14 @@ func setName(manifest *v1alpha1.Manifest) {
 	}
 }
 
+// GetInstallationName returns the name which keys the Manifest of the installation and labels its
+// objects. An installation into a target namespace is keyed by the namespace as well, so that
+// copies of a bundle installed into different namespaces under the same name coexist.
 func GetInstallationName() string {
 	installName := os.Getenv(CNAB_INSTALLATION_NAME_ENV_VAR)
+	if namespace := GetTargetNamespace(); installName != "" && namespace != "" {
+		return installName + "." + namespace
+	}
 	return installName
 }
 
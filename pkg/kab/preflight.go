@@ -0,0 +1,366 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+	log "github.com/sirupsen/logrus"
+	authv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+const (
+	PreflightPassed = "passed"
+	PreflightFailed = "failed"
+)
+
+// verbs kubectl needs on every object of the manifest to apply it, and to list and delete it on
+// uninstall
+var installVerbs = []string{"get", "list", "create", "patch", "delete"}
+
+type PreflightResult struct {
+	Check   string
+	Result  string
+	Details string
+}
+
+// Preflight checks that the manifest can be installed on the cluster: that the installation does
+// not exist yet, that the user may apply every object, and that the cluster meets the minimum
+// kubernetes version and the requirements declared in the manifest. All the checks are run so that
+// every failure is reported at once.
+func (c *Client) Preflight(manifest *v1alpha1.Manifest) ([]PreflightResult, error) {
+	results := []PreflightResult{c.checkInstallationName(manifest)}
+	if manifest.Spec.MinKubernetesVersion != "" {
+		results = append(results, c.checkKubernetesVersion(manifest.Spec.MinKubernetesVersion))
+	}
+	permissions, err := c.checkPermissions(manifest)
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, permissions...)
+	if manifest.Spec.Requirements != nil {
+		results = append(results, c.checkRequirements(*manifest.Spec.Requirements)...)
+	}
+	return results, nil
+}
+
+func (c *Client) checkInstallationName(manifest *v1alpha1.Manifest) PreflightResult {
+	result := PreflightResult{Check: "installation", Result: PreflightPassed}
+	old, err := c.kabClient.ProjectriffV1alpha1().Manifests().Get(manifest.Name, metav1.GetOptions{})
+	if err != nil && !k8serr.IsNotFound(err) {
+		return failed(result, "could not look up installation %s: %v", manifest.Name, err)
+	}
+	if err == nil && !isEmpty(old) {
+		return failed(result, "installation %s already exists", manifest.Name)
+	}
+	return result
+}
+
+func (c *Client) checkKubernetesVersion(minVersion string) PreflightResult {
+	result := PreflightResult{Check: "kubernetes version", Result: PreflightPassed}
+	min, err := version.ParseGeneric(minVersion)
+	if err != nil {
+		return failed(result, "invalid minimum version %s: %v", minVersion, err)
+	}
+	info, err := c.coreClient.Discovery().ServerVersion()
+	if err != nil {
+		return failed(result, "could not get server version: %v", err)
+	}
+	server, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return failed(result, "could not parse server version %s: %v", info.GitVersion, err)
+	}
+	if !server.AtLeast(min) {
+		return failed(result, "server version %s is older than the minimum %s", info.GitVersion, minVersion)
+	}
+	result.Details = info.GitVersion
+	return result
+}
+
+type apiResource struct {
+	group      string
+	resource   string
+	namespaced bool
+}
+
+type permission struct {
+	group     string
+	resource  string
+	namespace string
+}
+
+func (p permission) String() string {
+	name := p.resource
+	if p.group != "" {
+		name = p.resource + "." + p.group
+	}
+	if p.namespace != "" {
+		return fmt.Sprintf("%s in namespace %s", name, p.namespace)
+	}
+	return name
+}
+
+// checkPermissions runs a SelfSubjectAccessReview for every verb apply needs on every kind of the
+// manifest. Kinds unknown to the server, typically defined by a CRD of the manifest, cannot be
+// checked before their CRD is installed and are skipped.
+func (c *Client) checkPermissions(manifest *v1alpha1.Manifest) ([]PreflightResult, error) {
+	resources, err := c.discoverResources()
+	if err != nil {
+		return []PreflightResult{{Check: "permissions", Result: PreflightFailed, Details: fmt.Sprintf("could not discover api resources: %v", err)}}, nil
+	}
+
+	// the CRD and Manifest created for every installation
+	permissions := []permission{
+		{group: "apiextensions.k8s.io", resource: "customresourcedefinitions"},
+		{group: v1alpha1.GroupName, resource: NAME},
+	}
+	seen := map[permission]bool{}
+	for _, res := range manifest.Spec.Resources {
+		if res.Deferred || res.Test {
+			continue
+		}
+		objects, err := scan.ListObjectsFromContent([]byte(res.Content))
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objects {
+			gv, err := schema.ParseGroupVersion(obj.GetAPIVersion())
+			if err != nil {
+				return nil, err
+			}
+			r, ok := resources[gv.Group+"/"+obj.GetKind()]
+			if !ok {
+				log.Debugf("skipping permission check of %s, the kind is not known to the server", objectName(obj))
+				continue
+			}
+			p := permission{group: r.group, resource: r.resource}
+			if r.namespaced {
+				p.namespace = obj.GetNamespace()
+				if p.namespace == "" {
+					p.namespace = metav1.NamespaceDefault
+				}
+			}
+			if !seen[p] {
+				seen[p] = true
+				permissions = append(permissions, p)
+			}
+		}
+	}
+
+	results := []PreflightResult{}
+	for _, p := range permissions {
+		denied := []string{}
+		for _, verb := range installVerbs {
+			allowed, err := c.canI(verb, p)
+			if err != nil {
+				results = append(results, PreflightResult{Check: "permissions", Result: PreflightFailed, Details: err.Error()})
+				break
+			}
+			if !allowed {
+				denied = append(denied, verb)
+			}
+		}
+		if len(denied) > 0 {
+			results = append(results, PreflightResult{Check: "permissions", Result: PreflightFailed, Details: fmt.Sprintf("cannot %s %s", strings.Join(denied, ", "), p)})
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, PreflightResult{Check: "permissions", Result: PreflightPassed, Details: fmt.Sprintf("%d resources checked", len(permissions))})
+	}
+	return results, nil
+}
+
+// discoverResources maps group/Kind to the api resource serving it
+func (c *Client) discoverResources() (map[string]apiResource, error) {
+	lists, err := c.coreClient.Discovery().ServerResources()
+	if err != nil && len(lists) == 0 {
+		return nil, err
+	}
+	resources := map[string]apiResource{}
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if strings.Contains(r.Name, "/") {
+				// subresource
+				continue
+			}
+			resources[gv.Group+"/"+r.Kind] = apiResource{group: gv.Group, resource: r.Name, namespaced: r.Namespaced}
+		}
+	}
+	return resources, nil
+}
+
+func (c *Client) canI(verb string, p permission) (bool, error) {
+	review, err := c.coreClient.AuthorizationV1().SelfSubjectAccessReviews().Create(&authv1.SelfSubjectAccessReview{
+		Spec: authv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authv1.ResourceAttributes{
+				Verb:      verb,
+				Group:     p.group,
+				Resource:  p.resource,
+				Namespace: p.namespace,
+			},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("could not review access to %s: %v", p, err)
+	}
+	return review.Status.Allowed, nil
+}
+
+func (c *Client) checkRequirements(requirements v1alpha1.KabRequirements) []PreflightResult {
+	results := []PreflightResult{}
+	nodes, err := c.coreClient.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return append(results, PreflightResult{Check: "requirements", Result: PreflightFailed, Details: fmt.Sprintf("could not list nodes: %v", err)})
+	}
+
+	if requirements.LoadBalancer {
+		results = append(results, c.checkLoadBalancer(nodes.Items))
+	}
+	if requirements.Nodes == 0 && requirements.CPU == "" && requirements.Memory == "" {
+		return results
+	}
+
+	count := 0
+	cpu := resource.Quantity{}
+	memory := resource.Quantity{}
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable || !isNodeReady(node) {
+			continue
+		}
+		count++
+		cpu.Add(node.Status.Allocatable[corev1.ResourceCPU])
+		memory.Add(node.Status.Allocatable[corev1.ResourceMemory])
+	}
+	if requirements.Nodes > 0 {
+		result := PreflightResult{Check: "nodes", Result: PreflightPassed, Details: fmt.Sprintf("%d ready nodes", count)}
+		if count < requirements.Nodes {
+			result = failed(result, "%d ready nodes, %d required", count, requirements.Nodes)
+		}
+		results = append(results, result)
+	}
+	if requirements.CPU != "" {
+		results = append(results, checkCapacity("cpu", cpu, requirements.CPU))
+	}
+	if requirements.Memory != "" {
+		results = append(results, checkCapacity("memory", memory, requirements.Memory))
+	}
+	return results
+}
+
+// checkLoadBalancer looks for a cloud provider, which implements LoadBalancer services, or for an
+// existing LoadBalancer service which was assigned an address
+func (c *Client) checkLoadBalancer(nodes []corev1.Node) PreflightResult {
+	result := PreflightResult{Check: "load balancer", Result: PreflightPassed}
+	nodePort, err := isNodePortSet()
+	if err != nil {
+		return failed(result, "%v", err)
+	}
+	if nodePort {
+		result.Details = "not required with node_port"
+		return result
+	}
+	for _, node := range nodes {
+		if node.Spec.ProviderID != "" {
+			result.Details = "cloud provider detected"
+			return result
+		}
+	}
+	services, err := c.coreClient.CoreV1().Services(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return failed(result, "could not list services: %v", err)
+	}
+	for _, service := range services.Items {
+		if service.Spec.Type == corev1.ServiceTypeLoadBalancer && len(service.Status.LoadBalancer.Ingress) > 0 {
+			result.Details = "load balancer detected"
+			return result
+		}
+	}
+	return failed(result, "no load balancer support detected, set the node_port parameter to use NodePort services")
+}
+
+func checkCapacity(name string, allocatable resource.Quantity, required string) PreflightResult {
+	result := PreflightResult{Check: name, Result: PreflightPassed, Details: fmt.Sprintf("%s allocatable", allocatable.String())}
+	requiredQuantity, err := resource.ParseQuantity(required)
+	if err != nil {
+		return failed(result, "invalid requirement %s: %v", required, err)
+	}
+	if allocatable.Cmp(requiredQuantity) < 0 {
+		return failed(result, "%s allocatable, %s required", allocatable.String(), required)
+	}
+	return result
+}
+
+func isNodeReady(node corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func failed(result PreflightResult, format string, args ...interface{}) PreflightResult {
+	result.Result = PreflightFailed
+	result.Details = fmt.Sprintf(format, args...)
+	return result
+}
+
+// PreflightSucceeded returns true when no check failed
+func PreflightSucceeded(results []PreflightResult) bool {
+	for _, result := range results {
+		if result.Result == PreflightFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// WritePreflightReport writes the results as a table with one row per check, failures first
+func WritePreflightReport(out io.Writer, results []PreflightResult) error {
+	sorted := append([]PreflightResult{}, results...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Result == PreflightFailed && sorted[j].Result != PreflightFailed
+	})
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	_, err := fmt.Fprintln(w, "CHECK\tRESULT\tDETAILS")
+	if err != nil {
+		return err
+	}
+	for _, result := range sorted {
+		_, err = fmt.Fprintf(w, "%s\t%s\t%s\n", result.Check, result.Result, result.Details)
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,213 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	"bytes"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/client/clientset/versioned/fake"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	authv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/testing"
+)
+
+var _ = Describe("Preflight Tests", func() {
+
+	const content = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+  namespace: riff-system
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: riff-system
+---
+apiVersion: build.projectriff.io/v1alpha1
+kind: Function
+metadata:
+  name: square
+`
+
+	var (
+		client         *kab.Client
+		fakeKubeClient *kubefake.Clientset
+		fakeKabClient  *fake.Clientset
+		manifest       *v1alpha1.Manifest
+		reviews        []authv1.ResourceAttributes
+		denied         map[string]bool
+		results        []kab.PreflightResult
+		err            error
+	)
+
+	readyNode := func(name string, providerID string, cpu string, memory string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.NodeSpec{ProviderID: providerID},
+			Status: corev1.NodeStatus{
+				Allocatable: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(cpu),
+					corev1.ResourceMemory: resource.MustParse(memory),
+				},
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			},
+		}
+	}
+
+	failures := func() []kab.PreflightResult {
+		failed := []kab.PreflightResult{}
+		for _, result := range results {
+			if result.Result == kab.PreflightFailed {
+				failed = append(failed, result)
+			}
+		}
+		return failed
+	}
+
+	BeforeEach(func() {
+		reviews = nil
+		denied = map[string]bool{}
+		manifest = &v1alpha1.Manifest{
+			ObjectMeta: metav1.ObjectMeta{Name: "riff"},
+			Spec: v1alpha1.KabSpec{
+				Resources: []v1alpha1.KabResource{{Name: "riff", Content: content}},
+			},
+		}
+		fakeKabClient = fake.NewSimpleClientset()
+	})
+
+	JustBeforeEach(func() {
+		fakeKubeClient.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: "v1.14.3-gke.11"}
+		fakeKubeClient.Resources = []*metav1.APIResourceList{
+			{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{
+					{Name: "namespaces", Kind: "Namespace"},
+					{Name: "pods", Kind: "Pod", Namespaced: true},
+					{Name: "pods/log", Kind: "Pod", Namespaced: true},
+				},
+			},
+			{
+				GroupVersion: "apps/v1",
+				APIResources: []metav1.APIResource{{Name: "deployments", Kind: "Deployment", Namespaced: true}},
+			},
+		}
+		fakeKubeClient.PrependReactor("create", "selfsubjectaccessreviews", func(action testing.Action) (bool, runtime.Object, error) {
+			review := action.(testing.CreateAction).GetObject().(*authv1.SelfSubjectAccessReview)
+			attributes := *review.Spec.ResourceAttributes
+			reviews = append(reviews, attributes)
+			review.Status.Allowed = !denied[attributes.Verb+" "+attributes.Resource]
+			return true, review, nil
+		})
+		client = kab.NewKnbClient(fakeKubeClient, nil, fakeKabClient, nil, nil)
+		results, err = client.Preflight(manifest)
+	})
+
+	Context("when the cluster meets every requirement", func() {
+		BeforeEach(func() {
+			fakeKubeClient = kubefake.NewSimpleClientset(
+				readyNode("node1", "gce://node1", "2", "4Gi"),
+				readyNode("node2", "gce://node2", "2", "4Gi"),
+			)
+			manifest.Spec.MinKubernetesVersion = "1.14"
+			manifest.Spec.Requirements = &v1alpha1.KabRequirements{LoadBalancer: true, Nodes: 2, CPU: "4", Memory: "8Gi"}
+		})
+
+		It("all the checks pass", func() {
+			Expect(err).To(BeNil())
+			Expect(failures()).To(BeEmpty())
+			Expect(kab.PreflightSucceeded(results)).To(BeTrue())
+			Expect(results).To(ContainElement(kab.PreflightResult{Check: "kubernetes version", Result: kab.PreflightPassed, Details: "v1.14.3-gke.11"}))
+		})
+
+		It("the permissions of every known kind are reviewed", func() {
+			Expect(reviews).To(ContainElement(authv1.ResourceAttributes{Verb: "create", Group: "apps", Resource: "deployments", Namespace: "riff-system"}))
+			Expect(reviews).To(ContainElement(authv1.ResourceAttributes{Verb: "patch", Resource: "namespaces"}))
+			Expect(reviews).To(ContainElement(authv1.ResourceAttributes{Verb: "list", Group: "apps", Resource: "deployments", Namespace: "riff-system"}))
+			Expect(reviews).To(ContainElement(authv1.ResourceAttributes{Verb: "delete", Group: "apps", Resource: "deployments", Namespace: "riff-system"}))
+			Expect(reviews).To(ContainElement(authv1.ResourceAttributes{Verb: "create", Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}))
+			Expect(reviews).To(ContainElement(authv1.ResourceAttributes{Verb: "create", Group: "projectriff.io", Resource: "manifests"}))
+			for _, review := range reviews {
+				Expect(review.Group).NotTo(Equal("build.projectriff.io"))
+			}
+		})
+	})
+
+	Context("when the cluster does not meet the requirements", func() {
+		BeforeEach(func() {
+			fakeKubeClient = kubefake.NewSimpleClientset(readyNode("node1", "", "2", "4Gi"))
+			fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				return true, manifest, nil
+			})
+			denied["create deployments"] = true
+			denied["patch deployments"] = true
+			manifest.Spec.MinKubernetesVersion = "1.15.0"
+			manifest.Spec.Requirements = &v1alpha1.KabRequirements{LoadBalancer: true, Nodes: 3, Memory: "8Gi"}
+		})
+
+		It("every failure is reported", func() {
+			Expect(err).To(BeNil())
+			Expect(kab.PreflightSucceeded(results)).To(BeFalse())
+			Expect(failures()).To(ConsistOf(
+				kab.PreflightResult{Check: "installation", Result: kab.PreflightFailed, Details: "installation riff already exists"},
+				kab.PreflightResult{Check: "kubernetes version", Result: kab.PreflightFailed, Details: "server version v1.14.3-gke.11 is older than the minimum 1.15.0"},
+				kab.PreflightResult{Check: "permissions", Result: kab.PreflightFailed, Details: "cannot create, patch deployments.apps in namespace riff-system"},
+				kab.PreflightResult{Check: "load balancer", Result: kab.PreflightFailed, Details: "no load balancer support detected, set the node_port parameter to use NodePort services"},
+				kab.PreflightResult{Check: "nodes", Result: kab.PreflightFailed, Details: "1 ready nodes, 3 required"},
+				kab.PreflightResult{Check: "memory", Result: kab.PreflightFailed, Details: "4Gi allocatable, 8Gi required"},
+			))
+		})
+
+		It("the report lists the failures first", func() {
+			out := &bytes.Buffer{}
+			Expect(kab.WritePreflightReport(out, results)).To(Succeed())
+			Expect(out.String()).To(HavePrefix("CHECK "))
+			lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+			Expect(lines).To(HaveLen(7))
+			Expect(string(lines[1])).To(ContainSubstring("failed"))
+		})
+	})
+
+	Context("when node_port is set", func() {
+		BeforeEach(func() {
+			os.Setenv(kab.NODE_PORT_ENV_VAR, "true")
+			fakeKubeClient = kubefake.NewSimpleClientset(readyNode("node1", "", "2", "4Gi"))
+			manifest.Spec.Requirements = &v1alpha1.KabRequirements{LoadBalancer: true}
+		})
+
+		AfterEach(func() {
+			os.Unsetenv(kab.NODE_PORT_ENV_VAR)
+		})
+
+		It("a load balancer is not required", func() {
+			Expect(failures()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// Progress events are logged with an "event" field so that the output of the installer can be
+// followed by machines, typically with LOG_FORMAT=json
+const (
+	EVENT_FIELD    = "event"
+	RESOURCE_FIELD = "resource"
+	ACTION_FIELD   = "action"
+	ATTEMPTS_FIELD = "attempts"
+	CHECK_FIELD    = "check"
+	KINDS_FIELD    = "kinds"
+	DRY_RUN_FIELD  = "dry_run"
+	HOOK_FIELD     = "hook"
+	POD_FIELD      = "pod"
+
+	EventResourceStarted = "resource_started"
+	EventResourceApplied = "resource_applied"
+	EventCheckPassed     = "check_passed"
+	EventCheckFailed     = "check_failed"
+	EventResourceDone    = "resource_done"
+	EventObjectsDeleted  = "objects_deleted"
+	EventActionDone      = "action_done"
+	EventHookStarted     = "hook_started"
+	EventHookLog         = "hook_log"
+	EventHookDone        = "hook_done"
+	EventTestStarted     = "test_started"
+	EventTestLog         = "test_log"
+	EventTestPassed      = "test_passed"
+	EventTestFailed      = "test_failed"
+)
+
+// resourceEvent returns a log entry for a progress event of a resource
+func resourceEvent(event string, resource string) *log.Entry {
+	return log.WithFields(log.Fields{
+		EVENT_FIELD:    event,
+		RESOURCE_FIELD: resource,
+	})
+}
+
+// actionEvent returns a log entry for a progress event of the whole action
+func actionEvent(event string, action string) *log.Entry {
+	return log.WithFields(log.Fields{
+		EVENT_FIELD:  event,
+		ACTION_FIELD: action,
+	})
+}
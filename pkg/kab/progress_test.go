@@ -0,0 +1,166 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/client/clientset/versioned/fake"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	mockkubectl "github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl/mocks"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/testing"
+)
+
+var _ = Describe("Progress Events Tests", func() {
+
+	var (
+		mockKubeCtl *mockkubectl.KubeCtl
+		resMan      kab.ResourceManager
+		resource    v1alpha1.KabResource
+		out         *bytes.Buffer
+		err         error
+	)
+
+	events := func() []map[string]interface{} {
+		entries := []map[string]interface{}{}
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			entry := map[string]interface{}{}
+			Expect(json.Unmarshal([]byte(line), &entry)).To(Succeed())
+			if _, ok := entry[kab.EVENT_FIELD]; ok {
+				entries = append(entries, entry)
+			}
+		}
+		return entries
+	}
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		log.SetOutput(out)
+		log.SetFormatter(&log.JSONFormatter{})
+		// progress events are emitted at the default level
+		log.SetLevel(log.InfoLevel)
+
+		mockKubeCtl = new(mockkubectl.KubeCtl)
+		resMan = kab.NewResourceManager(mockKubeCtl, kubefake.NewSimpleClientset(&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "riff-system", Name: "controller", Labels: map[string]string{"app": "riff"}},
+			Status:     v1.PodStatus{Phase: v1.PodRunning},
+		}))
+		resource = v1alpha1.KabResource{
+			Name:    "res1",
+			Content: "kind: Namespace",
+		}
+	})
+
+	AfterEach(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFormatter(&log.TextFormatter{})
+		log.SetLevel(log.InfoLevel)
+	})
+
+	Context("when a resource is installed", func() {
+		It("started, applied and done events are emitted", func() {
+			mockKubeCtl.On("ExecStdin", []string{"apply", "-f", "-"}, mock.Anything).Return("", errors.New("conflict")).Once()
+			mockKubeCtl.On("ExecStdin", []string{"apply", "-f", "-"}, mock.Anything).Return("created", nil).Once()
+
+			err = resMan.Install(resource, wait.Backoff{Steps: 2})
+			Expect(err).To(BeNil())
+			err = resMan.Check(resource, wait.Backoff{Steps: 2})
+			Expect(err).To(BeNil())
+
+			entries := events()
+			Expect(entries).To(HaveLen(3))
+			Expect(entries[0]).To(HaveKeyWithValue(kab.EVENT_FIELD, kab.EventResourceStarted))
+			Expect(entries[0]).To(HaveKeyWithValue(kab.RESOURCE_FIELD, "res1"))
+			Expect(entries[1]).To(HaveKeyWithValue(kab.EVENT_FIELD, kab.EventResourceApplied))
+			Expect(entries[1]).To(HaveKeyWithValue(kab.ATTEMPTS_FIELD, BeNumerically("==", 2)))
+			Expect(entries[1]).To(HaveKeyWithValue("level", "info"))
+			Expect(entries[2]).To(HaveKeyWithValue(kab.EVENT_FIELD, kab.EventResourceDone))
+		})
+	})
+
+	Context("when a check passes", func() {
+		It("a check passed event is emitted with the attempts", func() {
+			resource.Checks = []v1alpha1.ResourceChecks{{
+				Kind:      "Pod",
+				Namespace: "riff-system",
+				Selector:  metav1.LabelSelector{MatchLabels: map[string]string{"app": "riff"}},
+				Pattern:   "Running",
+			}}
+
+			err = resMan.Check(resource, wait.Backoff{Steps: 2})
+			Expect(err).To(BeNil())
+
+			entries := events()
+			Expect(entries).To(HaveLen(2))
+			Expect(entries[0]).To(HaveKeyWithValue(kab.EVENT_FIELD, kab.EventCheckPassed))
+			Expect(entries[0]).To(HaveKeyWithValue(kab.CHECK_FIELD, `Pod app=riff in namespace "riff-system"`))
+			Expect(entries[0]).To(HaveKeyWithValue(kab.ATTEMPTS_FIELD, BeNumerically("==", 1)))
+			Expect(entries[0]).To(HaveKeyWithValue("level", "info"))
+			Expect(entries[1]).To(HaveKeyWithValue(kab.EVENT_FIELD, kab.EventResourceDone))
+		})
+	})
+
+	Context("when a check fails", func() {
+		It("a check failed event is emitted with the attempts", func() {
+			resource.Checks = []v1alpha1.ResourceChecks{{Kind: "Deployment", Namespace: "ns"}}
+
+			err = resMan.Check(resource, wait.Backoff{Steps: 2})
+			Expect(err).To(MatchError("unknown resource kind: Deployment"))
+
+			entries := events()
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0]).To(HaveKeyWithValue(kab.EVENT_FIELD, kab.EventCheckFailed))
+			Expect(entries[0]).To(HaveKeyWithValue(kab.CHECK_FIELD, `Deployment  in namespace "ns"`))
+			Expect(entries[0]).To(HaveKeyWithValue(kab.ATTEMPTS_FIELD, BeNumerically("==", 1)))
+			Expect(entries[0]).To(HaveKeyWithValue("level", "warning"))
+		})
+	})
+
+	Context("when an action completes", func() {
+		It("an action done event is emitted with a single line message", func() {
+			manifest := &v1alpha1.Manifest{ObjectMeta: metav1.ObjectMeta{Name: "riff"}}
+			fakeKabClient := fake.NewSimpleClientset()
+			fakeKabClient.PrependReactor("*", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				return true, manifest.DeepCopy(), nil
+			})
+			fakeKubeClient := kubefake.NewSimpleClientset(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+			client := kab.NewKnbClient(fakeKubeClient, nil, fakeKabClient, nil, mockKubeCtl)
+
+			err = client.Upgrade(manifest)
+			Expect(err).To(BeNil())
+
+			entries := events()
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0]).To(HaveKeyWithValue(kab.EVENT_FIELD, kab.EventActionDone))
+			Expect(entries[0]).To(HaveKeyWithValue("msg", "Kubernetes Application Bundle upgraded"))
+		})
+	})
+})
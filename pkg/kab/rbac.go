@@ -0,0 +1,268 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// verbs needed to apply, look up and uninstall the objects of a manifest
+var objectVerbs = []string{"get", "list", "create", "patch", "delete"}
+
+// clusterScopedKinds are the built-in kinds which are not namespaced, keyed by group/Kind
+var clusterScopedKinds = map[string]bool{
+	"/Namespace":        true,
+	"/Node":             true,
+	"/PersistentVolume": true,
+	"admissionregistration.k8s.io/MutatingWebhookConfiguration":   true,
+	"admissionregistration.k8s.io/ValidatingWebhookConfiguration": true,
+	"apiextensions.k8s.io/CustomResourceDefinition":               true,
+	"apiregistration.k8s.io/APIService":                           true,
+	"policy/PodSecurityPolicy":                                    true,
+	"rbac.authorization.k8s.io/ClusterRole":                       true,
+	"rbac.authorization.k8s.io/ClusterRoleBinding":                true,
+	"scheduling.k8s.io/PriorityClass":                             true,
+	"storage.k8s.io/StorageClass":                                 true,
+	"storage.k8s.io/VolumeAttachment":                             true,
+	"storage.k8s.io/CSIDriver":                                    true,
+	"storage.k8s.io/CSINode":                                      true,
+}
+
+// rbacKinds are the kinds which can only be created with all the permissions they grant, unless
+// the bind and escalate verbs are granted
+var rbacKinds = map[string]bool{
+	"rbac.authorization.k8s.io/Role":               true,
+	"rbac.authorization.k8s.io/RoleBinding":        true,
+	"rbac.authorization.k8s.io/ClusterRole":        true,
+	"rbac.authorization.k8s.io/ClusterRoleBinding": true,
+}
+
+type rbacResource struct {
+	group    string
+	resource string
+}
+
+// rbacRules collects the verbs needed on each resource, per namespace. The empty namespace holds
+// the rules for cluster scoped resources.
+type rbacRules map[string]map[rbacResource]map[string]bool
+
+func (r rbacRules) add(namespace string, group string, resource string, verbs ...string) {
+	if r[namespace] == nil {
+		r[namespace] = map[rbacResource]map[string]bool{}
+	}
+	key := rbacResource{group: group, resource: resource}
+	if r[namespace][key] == nil {
+		r[namespace][key] = map[string]bool{}
+	}
+	for _, verb := range verbs {
+		r[namespace][key][verb] = true
+	}
+}
+
+// WriteRBAC writes the ClusterRole, and a Role for every namespace, granting the least privileges
+// needed to install, upgrade and uninstall the manifest. The resource contents must be inlined.
+func WriteRBAC(manifest *v1alpha1.Manifest, out io.Writer) error {
+	rules, err := manifestRules(manifest)
+	if err != nil {
+		return err
+	}
+	namespaces := []string{}
+	for namespace := range rules {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	name := manifest.Name + "-installer"
+	for _, namespace := range namespaces {
+		var role interface{}
+		if namespace == "" {
+			role = rbacv1.ClusterRole{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Rules:      policyRules(rules[namespace]),
+			}
+		} else {
+			role = rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				Rules:      policyRules(rules[namespace]),
+			}
+		}
+		content, err := yaml.Marshal(role)
+		if err != nil {
+			return err
+		}
+		// creationTimestamp is always marshalled
+		content = bytes.Replace(content, []byte("  creationTimestamp: null\n"), nil, 1)
+		_, err = fmt.Fprintf(out, "---\n%s", content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func manifestRules(manifest *v1alpha1.Manifest) (rbacRules, error) {
+	rules := rbacRules{}
+
+	// the CRD and Manifest of every installation
+	rules.add("", "apiextensions.k8s.io", "customresourcedefinitions", "get", "create")
+	rules.add("", v1alpha1.GroupName, NAME, "get", "list", "create", "update", "delete")
+	// installations are looked up in every namespace
+	rules.add("", "", "namespaces", "list")
+	// events are recorded on the cluster scoped Manifest
+	rules.add(metav1.NamespaceDefault, "", "events", "create")
+	if len(manifest.Spec.Namespaces) > 0 {
+		// declared namespaces are created, labeled and deleted by the installer
+		rules.add("", "", "namespaces", "get", "create", "update", "delete")
+	}
+
+	objects := []unstructured.Unstructured{}
+	// the objects of tests are created in a namespace named for each run
+	testObjects := map[int]bool{}
+	for _, resource := range manifest.Spec.Resources {
+		resourceObjects, err := scan.ListObjectsFromContent([]byte(resource.Content))
+		if err != nil {
+			return nil, fmt.Errorf("error scanning resource %s: %v", resource.Name, err)
+		}
+		if resource.Test {
+			for i := range resourceObjects {
+				testObjects[len(objects)+i] = true
+			}
+			rules.add("", "", "namespaces", "create", "delete")
+			rules.add("", "", "pods", "list")
+			rules.add("", "", "pods/log", "get")
+		}
+		objects = append(objects, resourceObjects...)
+		for _, check := range resource.Checks {
+			rules.add(check.Namespace, "", strings.ToLower(check.Kind)+"s", "list")
+		}
+		if resource.Hook != "" {
+			workloads, err := hookWorkloads(resource, metav1.NamespaceDefault)
+			if err != nil {
+				return nil, fmt.Errorf("error scanning resource %s: %v", resource.Name, err)
+			}
+			// the logs of the jobs and pods of hooks are written to the installer output
+			for _, workload := range workloads {
+				if isJob(workload) {
+					rules.add(workload.GetNamespace(), "", "pods", "list")
+				}
+				rules.add(workload.GetNamespace(), "", "pods/log", "get")
+			}
+		}
+	}
+
+	crds := manifestCRDs(objects)
+	for i, obj := range objects {
+		gv, err := schema.ParseGroupVersion(obj.GetAPIVersion())
+		if err != nil {
+			return nil, fmt.Errorf("invalid apiVersion of %s: %v", objectName(obj), err)
+		}
+		key := gv.Group + "/" + obj.GetKind()
+		crd, definedByManifest := crds[key]
+
+		resource := crd.resource
+		if !definedByManifest {
+			plural, _ := meta.UnsafeGuessKindToResource(gv.WithKind(obj.GetKind()))
+			resource = plural.Resource
+		}
+		namespace := ""
+		if !testObjects[i] && isNamespaced(obj, crds) {
+			namespace = obj.GetNamespace()
+			if namespace == "" {
+				namespace = metav1.NamespaceDefault
+			}
+		}
+
+		rules.add(namespace, gv.Group, resource, objectVerbs...)
+		if rbacKinds[key] {
+			rules.add(namespace, gv.Group, resource, "bind", "escalate")
+		}
+	}
+
+	if manifest.Spec.Requirements != nil {
+		// preflight checks
+		rules.add("", "", "nodes", "list")
+		if manifest.Spec.Requirements.LoadBalancer {
+			rules.add("", "", "services", "list")
+		}
+	}
+	return rules, nil
+}
+
+type crdInfo struct {
+	resource   string
+	namespaced bool
+}
+
+// manifestCRDs returns the resources defined by the CRDs of the manifest, keyed by group/Kind
+func manifestCRDs(objects []unstructured.Unstructured) map[string]crdInfo {
+	crds := map[string]crdInfo{}
+	for _, obj := range objects {
+		if obj.GetKind() != "CustomResourceDefinition" {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+		plural, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "plural")
+		scope, _, _ := unstructured.NestedString(obj.Object, "spec", "scope")
+		crds[group+"/"+kind] = crdInfo{resource: plural, namespaced: scope != "Cluster"}
+	}
+	return crds
+}
+
+// policyRules merges the resources of a group which need the same verbs into a single rule
+func policyRules(resources map[rbacResource]map[string]bool) []rbacv1.PolicyRule {
+	merged := map[string]*rbacv1.PolicyRule{}
+	keys := []string{}
+	for res, verbSet := range resources {
+		verbs := []string{}
+		for verb := range verbSet {
+			verbs = append(verbs, verb)
+		}
+		sort.Strings(verbs)
+		key := res.group + "\x00" + strings.Join(verbs, ",")
+		rule, ok := merged[key]
+		if !ok {
+			rule = &rbacv1.PolicyRule{APIGroups: []string{res.group}, Verbs: verbs}
+			merged[key] = rule
+			keys = append(keys, key)
+		}
+		rule.Resources = append(rule.Resources, res.resource)
+	}
+	sort.Strings(keys)
+	rules := []rbacv1.PolicyRule{}
+	for _, key := range keys {
+		rule := merged[key]
+		sort.Strings(rule.Resources)
+		rules = append(rules, *rule)
+	}
+	return rules
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const rbacContent = `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: riff-system
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+  namespace: riff-system
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: controller
+---
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  scope: Cluster
+  names:
+    kind: Widget
+    plural: widgets
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+`
+
+var _ = Describe("RBAC Tests", func() {
+
+	var (
+		manifest *v1alpha1.Manifest
+		out      *bytes.Buffer
+		err      error
+	)
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		manifest = &v1alpha1.Manifest{
+			Spec: v1alpha1.KabSpec{
+				Resources: []v1alpha1.KabResource{
+					{
+						Name:    "res1",
+						Content: rbacContent,
+						Checks: []v1alpha1.ResourceChecks{
+							{Kind: "Pod", Namespace: "riff-system"},
+						},
+					},
+				},
+			},
+		}
+		manifest.Name = "riff"
+	})
+
+	roles := func() map[string][]rbacv1.PolicyRule {
+		objects, err := scan.ListObjectsFromContent(out.Bytes())
+		Expect(err).To(BeNil())
+		roles := map[string][]rbacv1.PolicyRule{}
+		for _, obj := range objects {
+			Expect(obj.GetName()).To(Equal("riff-installer"))
+			role := rbacv1.ClusterRole{}
+			Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &role)).To(Succeed())
+			roles[obj.GetKind()+"/"+obj.GetNamespace()] = role.Rules
+		}
+		return roles
+	}
+
+	It("grants the objects of the manifest in their scope", func() {
+		err = kab.WriteRBAC(manifest, out)
+		Expect(err).To(BeNil())
+
+		roles := roles()
+		Expect(roles).To(HaveLen(3))
+		Expect(roles["ClusterRole/"]).To(ContainElement(rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"namespaces"},
+			Verbs:     []string{"create", "delete", "get", "list", "patch"},
+		}))
+		Expect(roles["ClusterRole/"]).To(ContainElement(rbacv1.PolicyRule{
+			APIGroups: []string{"example.com"},
+			Resources: []string{"widgets"},
+			Verbs:     []string{"create", "delete", "get", "list", "patch"},
+		}))
+		Expect(roles["ClusterRole/"]).To(ContainElement(rbacv1.PolicyRule{
+			APIGroups: []string{"rbac.authorization.k8s.io"},
+			Resources: []string{"clusterroles"},
+			Verbs:     []string{"bind", "create", "delete", "escalate", "get", "list", "patch"},
+		}))
+		Expect(roles["ClusterRole/"]).To(ContainElement(rbacv1.PolicyRule{
+			APIGroups: []string{"projectriff.io"},
+			Resources: []string{"manifests"},
+			Verbs:     []string{"create", "delete", "get", "list", "update"},
+		}))
+		Expect(roles["Role/riff-system"]).To(ConsistOf(
+			rbacv1.PolicyRule{
+				APIGroups: []string{""},
+				Resources: []string{"pods"},
+				Verbs:     []string{"list"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{"apps"},
+				Resources: []string{"deployments"},
+				Verbs:     []string{"create", "delete", "get", "list", "patch"},
+			},
+		))
+		Expect(roles["Role/default"]).To(ConsistOf(
+			rbacv1.PolicyRule{
+				APIGroups: []string{""},
+				Resources: []string{"configmaps"},
+				Verbs:     []string{"create", "delete", "get", "list", "patch"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{""},
+				Resources: []string{"events"},
+				Verbs:     []string{"create"},
+			},
+		))
+	})
+
+	It("grants the management of the declared namespaces", func() {
+		manifest.Spec.Namespaces = []v1alpha1.KabNamespace{{Name: "knative-serving"}}
+		err = kab.WriteRBAC(manifest, out)
+		Expect(err).To(BeNil())
+
+		Expect(roles()["ClusterRole/"]).To(ContainElement(rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"namespaces"},
+			Verbs:     []string{"create", "delete", "get", "list", "patch", "update"},
+		}))
+	})
+
+	It("fails when the content cannot be scanned", func() {
+		manifest.Spec.Resources[0].Content = "kind: [\n"
+		err = kab.WriteRBAC(manifest, out)
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -17,12 +17,16 @@
 package kab
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
 )
 
 // TODO this only supports checking Pods for phases, add more resources
@@ -47,9 +51,43 @@ func (rm *rm) isPodReady(check v1alpha1.ResourceChecks) (bool, error) {
 		return false, nil
 	}
 	for _, pod := range podList.Items {
-		if !strings.EqualFold(string(pod.Status.Phase), check.Pattern) {
+		value, err := podValue(pod, check.JsonPath)
+		if err != nil {
+			return false, err
+		}
+		if !strings.EqualFold(value, check.Pattern) {
 			return false, nil
 		}
 	}
 	return true, nil
 }
+
+// podValue returns the value at the jsonpath in the pod, its phase when there is no jsonpath
+func podValue(pod corev1.Pod, path string) (string, error) {
+	if path == "" {
+		return string(pod.Status.Phase), nil
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&pod)
+	if err != nil {
+		return "", err
+	}
+	parser := jsonpath.New("check").AllowMissingKeys(true)
+	err = parser.Parse(jsonpathTemplate(path))
+	if err != nil {
+		return "", fmt.Errorf("invalid jsonpath %s: %v", path, err)
+	}
+	var buf bytes.Buffer
+	err = parser.Execute(&buf, obj)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// jsonpathTemplate wraps a bare jsonpath like .status.phase in braces, as kubectl does
+func jsonpathTemplate(path string) string {
+	if strings.HasPrefix(path, "{") {
+		return path
+	}
+	return "{" + path + "}"
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+)
+
+// PrepareManifest inlines the content of every resource, puts the objects of charts without a
+// namespace into the namespace of the release, moves the namespaced objects into the target
+// namespace when one is set, applies the installation labels and NodePort patches and
+// relocates images, leaving the manifest ready to be installed. configmap:// and secret:// paths
+// are read from the cluster.
+func (c *Client) PrepareManifest(manifest *v1alpha1.Manifest) error {
+	err := manifest.InlineContentWith(c.resolvers())
+	if err != nil {
+		return fmt.Errorf("error while reading manifest: %v", err)
+	}
+	err = c.setChartNamespaces(manifest)
+	if err != nil {
+		return err
+	}
+	if namespace := GetTargetNamespace(); namespace != "" {
+		err = c.retargetManifest(manifest, namespace)
+		if err != nil {
+			return err
+		}
+	}
+	err = c.PatchManifest(manifest)
+	if err != nil {
+		return err
+	}
+	return c.MaybeRelocate(manifest)
+}
+
+// Render prepares the manifest and writes the objects that would be applied to the cluster as a
+// multi-document yaml, starting with the declared namespaces. Deferred and test resources are not
+// rendered. The cluster is not contacted.
+func (c *Client) Render(manifest *v1alpha1.Manifest, out io.Writer) error {
+	err := c.PrepareManifest(manifest)
+	if err != nil {
+		return err
+	}
+	for _, declared := range manifest.Spec.Namespaces {
+		content, err := yaml.Marshal(namespaceObject(declared).Object)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "---\n# Namespace: %s\n%s", declared.Name, content)
+		if err != nil {
+			return err
+		}
+	}
+	for _, resource := range manifest.Spec.Resources {
+		if resource.Deferred || resource.Test {
+			continue
+		}
+		content := strings.TrimPrefix(strings.TrimLeft(resource.Content, "\n"), "---\n")
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		_, err = fmt.Fprintf(out, "---\n# Resource: %s\n%s", resource.Name, content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	mockkustomize "github.com/projectriff/cnab-k8s-installer-base/pkg/kustomize/mocks"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Render Tests", func() {
+
+	var (
+		client        *kab.Client
+		mockKustomize *mockkustomize.Kustomizer
+		manifest      *v1alpha1.Manifest
+		out           *bytes.Buffer
+		err           error
+	)
+
+	BeforeEach(func() {
+		mockKustomize = new(mockkustomize.Kustomizer)
+		out = &bytes.Buffer{}
+		manifest = &v1alpha1.Manifest{
+			Spec: v1alpha1.KabSpec{
+				Resources: []v1alpha1.KabResource{
+					{
+						Name:    "res1",
+						Content: "---\nkind: Service\nspec:\n  type: LoadBalancer",
+					},
+					{
+						Name:     "deferred",
+						Content:  "kind: ConfigMap\n",
+						Deferred: true,
+					},
+				},
+			},
+		}
+
+		// the cluster is never contacted
+		client = kab.NewKnbClient(nil, nil, nil, mockKustomize, nil)
+	})
+
+	JustBeforeEach(func() {
+		os.Setenv(kab.NODE_PORT_ENV_VAR, "true")
+	})
+
+	JustAfterEach(func() {
+		os.Unsetenv(kab.NODE_PORT_ENV_VAR)
+	})
+
+	Context("when the manifest can be patched", func() {
+		It("the patched content of the resources is rendered", func() {
+			mockKustomize.On("ApplyLabels", mock.Anything, mock.Anything).Return(func(content string, labels map[string]string) []byte {
+				return []byte("metadata:\n  labels:\n    " + kab.LABEL_KEY_NAME + ": \"\"\n" + content)
+			}, nil)
+
+			err = client.Render(manifest, out)
+			Expect(err).To(BeNil())
+			Expect(out.String()).To(Equal(`---
+# Resource: res1
+metadata:
+  labels:
+    cnab-k8s-installer-installation-name: ""
+---
+kind: Service
+spec:
+  type: NodePort
+`))
+		})
+	})
+
+	Context("when a resource is a chart leaving out the namespaces", func() {
+		It("the namespaced objects are put into the namespace of the release", func() {
+			mockKustomize.On("ApplyLabels", mock.Anything, mock.Anything).Return(func(content string, labels map[string]string) []byte {
+				return []byte(content)
+			}, nil)
+			manifest.Spec.Resources = []v1alpha1.KabResource{
+				{Name: "plain", Chart: &v1alpha1.KabChart{Path: "./fixtures/charts/plain", Namespace: "riff-system"}},
+			}
+
+			err = client.Render(manifest, out)
+			Expect(err).To(BeNil())
+			objects, err := scan.ListObjectsFromContent(out.Bytes())
+			Expect(err).NotTo(HaveOccurred())
+			namespaces := map[string]string{}
+			for _, obj := range objects {
+				namespaces[obj.GetKind()] = obj.GetNamespace()
+			}
+			Expect(namespaces).To(Equal(map[string]string{"ConfigMap": "riff-system", "ClusterRole": "", "Service": "monitoring"}))
+		})
+	})
+
+	Context("when the manifest declares namespaces", func() {
+		It("the namespaces are rendered first", func() {
+			mockKustomize.On("ApplyLabels", mock.Anything, mock.Anything).Return(func(content string, labels map[string]string) []byte {
+				return []byte(content)
+			}, nil)
+			manifest.Spec.Namespaces = []v1alpha1.KabNamespace{
+				{Name: "riff-system", Labels: map[string]string{"istio-injection": "enabled"}},
+			}
+
+			err = client.Render(manifest, out)
+			Expect(err).To(BeNil())
+			Expect(out.String()).To(HavePrefix(`---
+# Namespace: riff-system
+apiVersion: v1
+kind: Namespace
+metadata:
+  labels:
+    cnab-k8s-installer-installation-name: ""
+    istio-injection: enabled
+  name: riff-system
+---
+# Resource: res1
+`))
+		})
+	})
+
+	Context("when the manifest cannot be patched", func() {
+		It("an error is returned", func() {
+			mockKustomize.On("ApplyLabels", mock.Anything, mock.Anything).Return(nil, errors.New("kustomize error"))
+
+			err = client.Render(manifest, out)
+			Expect(err).To(MatchError("kustomize error"))
+			Expect(out.String()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// resolvers returns the default resolvers plus, when the client is connected to a cluster, the
+// resolvers reading configmap:// and secret:// paths from the cluster.
+func (c *Client) resolvers() map[string]v1alpha1.Resolver {
+	resolvers := v1alpha1.DefaultResolvers()
+	if c.coreClient == nil {
+		return resolvers
+	}
+	resolvers["configmap"] = c.readConfigMap
+	resolvers["secret"] = c.readSecret
+	return resolvers
+}
+
+func (c *Client) readConfigMap(path string) ([]byte, error) {
+	namespace, name, key, err := v1alpha1.ParseClusterPath(path)
+	if err != nil {
+		return nil, err
+	}
+	configMap, err := c.coreClient.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	if value, ok := configMap.Data[key]; ok {
+		return []byte(value), nil
+	}
+	if value, ok := configMap.BinaryData[key]; ok {
+		return value, nil
+	}
+	return nil, fmt.Errorf("error reading %s: key %s not found in configmap %s/%s", path, key, namespace, name)
+}
+
+func (c *Client) readSecret(path string) ([]byte, error) {
+	namespace, name, key, err := v1alpha1.ParseClusterPath(path)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := c.coreClient.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("error reading %s: key %s not found in secret %s/%s", path, key, namespace, name)
+	}
+	return value, nil
+}
+
+// isSecretResource returns true for the resources read from a secret
+func isSecretResource(resource v1alpha1.KabResource) bool {
+	u, err := url.Parse(strings.TrimPrefix(resource.Path, v1alpha1.ResolvedPrefix))
+	return err == nil && u.Scheme == "secret"
+}
+
+// storedManifest returns a copy of the manifest to store in the cluster, without the content of the
+// resources read from a secret, which would be kept in plain text in the Manifest object
+func storedManifest(manifest *v1alpha1.Manifest) *v1alpha1.Manifest {
+	stored := manifest.DeepCopy()
+	for i := range stored.Spec.Resources {
+		if isSecretResource(stored.Spec.Resources[i]) {
+			stored.Spec.Resources[i].Content = ""
+		}
+	}
+	return stored
+}
+
+// readSecretContent reads again from their secret the content of the resources of a stored manifest
+func (c *Client) readSecretContent(manifest *v1alpha1.Manifest) error {
+	for i := range manifest.Spec.Resources {
+		resource := &manifest.Spec.Resources[i]
+		if resource.Content != "" || !isSecretResource(*resource) {
+			continue
+		}
+		content, err := c.readSecret(strings.TrimPrefix(resource.Path, v1alpha1.ResolvedPrefix))
+		if err != nil {
+			return fmt.Errorf("resource %s is not stored with the installation: %v", resource.Name, err)
+		}
+		resource.Content = string(content)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/client/clientset/versioned/fake"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	mockkubectl "github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl/mocks"
+	mockkustomize "github.com/projectriff/cnab-k8s-installer-base/pkg/kustomize/mocks"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/testing"
+)
+
+const config = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: riff-system
+`
+
+var _ = Describe("Cluster resolvers", func() {
+
+	var (
+		client        *kab.Client
+		mockKustomize *mockkustomize.Kustomizer
+		manifest      *v1alpha1.Manifest
+		err           error
+	)
+
+	BeforeEach(func() {
+		mockKustomize = new(mockkustomize.Kustomizer)
+		mockKustomize.On("ApplyLabels", mock.Anything, mock.Anything).Return(func(content string, labels map[string]string) []byte {
+			return []byte(content)
+		}, nil)
+		fakeKubeClient := kubefake.NewSimpleClientset(
+			&v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "riff-system", Name: "resources"},
+				Data:       map[string]string{"config.yaml": "kind: ConfigMap\n"},
+				BinaryData: map[string][]byte{"binary.yaml": []byte("kind: Service\n")},
+			},
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "riff-system", Name: "resources"},
+				Data: map[string][]byte{
+					"secret.yaml": []byte("kind: Secret\n"),
+					"config.yaml": []byte(config),
+				},
+			},
+		)
+		client = kab.NewKnbClient(fakeKubeClient, nil, nil, mockKustomize, nil)
+	})
+
+	JustBeforeEach(func() {
+		err = client.PrepareManifest(manifest)
+	})
+
+	Context("when resources are read from a configmap and a secret", func() {
+		BeforeEach(func() {
+			manifest = &v1alpha1.Manifest{Spec: v1alpha1.KabSpec{Resources: []v1alpha1.KabResource{
+				{Name: "config", Path: "configmap://riff-system/resources/config.yaml"},
+				{Name: "binary", Path: "configmap://riff-system/resources/binary.yaml"},
+				{Name: "secret", Path: "secret://riff-system/resources/secret.yaml", Sha256: v1alpha1.Sha256Digest([]byte("kind: Secret\n"))},
+			}}}
+		})
+
+		It("inlines their content", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest.Spec.Resources[0].Content).To(Equal("kind: ConfigMap\n"))
+			Expect(manifest.Spec.Resources[1].Content).To(Equal("kind: Service\n"))
+			Expect(manifest.Spec.Resources[2].Content).To(Equal("kind: Secret\n"))
+		})
+	})
+
+	Context("when the content does not match the digest", func() {
+		BeforeEach(func() {
+			manifest = &v1alpha1.Manifest{Spec: v1alpha1.KabSpec{Resources: []v1alpha1.KabResource{
+				{Name: "secret", Path: "secret://riff-system/resources/secret.yaml", Sha256: v1alpha1.Sha256Digest([]byte("kind: ConfigMap\n"))},
+			}}}
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("sha256")))
+		})
+	})
+
+	Context("when the key does not exist", func() {
+		BeforeEach(func() {
+			manifest = &v1alpha1.Manifest{Spec: v1alpha1.KabSpec{Resources: []v1alpha1.KabResource{
+				{Name: "config", Path: "configmap://riff-system/resources/missing.yaml"},
+			}}}
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("key missing.yaml not found in configmap riff-system/resources")))
+		})
+	})
+
+	Context("when the secret does not exist", func() {
+		BeforeEach(func() {
+			manifest = &v1alpha1.Manifest{Spec: v1alpha1.KabSpec{Resources: []v1alpha1.KabResource{
+				{Name: "secret", Path: "secret://default/resources/secret.yaml"},
+			}}}
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("not found")))
+		})
+	})
+
+	Context("when the client is offline", func() {
+		BeforeEach(func() {
+			client = kab.NewKnbClient(nil, nil, nil, mockKustomize, nil)
+			manifest = &v1alpha1.Manifest{Spec: v1alpha1.KabSpec{Resources: []v1alpha1.KabResource{
+				{Name: "config", Path: "configmap://riff-system/resources/config.yaml"},
+			}}}
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("configmap paths are only read when installing to a cluster")))
+		})
+	})
+})
+
+var _ = Describe("Resources read from a secret", func() {
+
+	var (
+		client      *kab.Client
+		mockKubectl *mockkubectl.KubeCtl
+		manifest    *v1alpha1.Manifest
+		stored      *v1alpha1.Manifest
+		err         error
+	)
+
+	BeforeEach(func() {
+		mockKustomize := new(mockkustomize.Kustomizer)
+		mockKustomize.On("ApplyLabels", mock.Anything, mock.Anything).Return(func(content string, labels map[string]string) []byte {
+			return []byte(content)
+		}, nil)
+		mockKubectl = new(mockkubectl.KubeCtl)
+		mockKubectl.On("Exec", mock.Anything).Return("", nil)
+		mockKubectl.On("ExecStdin", mock.Anything, mock.Anything).Return("", nil)
+		fakeKubeClient := kubefake.NewSimpleClientset(
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "riff-system", Name: "resources"},
+				Data:       map[string][]byte{"config.yaml": []byte(config)},
+			},
+		)
+		fakeKabClient := fake.NewSimpleClientset()
+		fakeKabClient.PrependReactor("*", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+			if update, ok := action.(testing.UpdateAction); ok {
+				stored = update.GetObject().(*v1alpha1.Manifest).DeepCopy()
+			}
+			return true, stored.DeepCopy(), nil
+		})
+		client = kab.NewKnbClient(fakeKubeClient, nil, fakeKabClient, mockKustomize, mockKubectl)
+
+		manifest = &v1alpha1.Manifest{
+			ObjectMeta: metav1.ObjectMeta{Name: "riff"},
+			Spec: v1alpha1.KabSpec{Resources: []v1alpha1.KabResource{
+				{Name: "config", Path: "secret://riff-system/resources/config.yaml"},
+			}},
+		}
+		err = client.PrepareManifest(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		// the installation stored without the content
+		stored = manifest.DeepCopy()
+		stored.Spec.Resources[0].Content = ""
+	})
+
+	It("the content is not stored with the installation on upgrade", func() {
+		err = client.Upgrade(manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.Spec.Resources[0].Content).To(Equal(config))
+		Expect(stored.Spec.Resources[0].Content).To(BeEmpty())
+	})
+
+	It("the content is read again from the secret on uninstall", func() {
+		err = client.Uninstall("riff")
+		Expect(err).NotTo(HaveOccurred())
+		args := mockKubectl.Calls[0].Arguments.Get(0).([]string)
+		Expect(args[:2]).To(Equal([]string{"delete", "ConfigMap"}))
+	})
+})
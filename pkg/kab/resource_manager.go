@@ -36,6 +36,7 @@ type rm struct {
 type ResourceManager interface {
 	Install(resource v1alpha1.KabResource, backOffSettings wait.Backoff) error
 	Check(resource v1alpha1.KabResource, backOffSettings wait.Backoff) error
+	DryRun(resource v1alpha1.KabResource) (string, error)
 }
 
 func NewResourceManager(kubectl kubectl.KubeCtl, coreClient kubernetes.Interface) *rm {
@@ -46,8 +47,10 @@ func (rm *rm) Install(res v1alpha1.KabResource, backOffSettings wait.Backoff) er
 	var installContent []byte
 	var err error
 
-	log.Infof("installing %s...", res.Name)
+	resourceEvent(EventResourceStarted, res.Name).Infof("installing %s...", res.Name)
+	attempts := 0
 	err = wait.ExponentialBackoff(backOffSettings, func() (bool, error) {
+		attempts++
 		if res.Content != "" {
 			installContent = []byte(res.Content)
 		} else {
@@ -65,6 +68,7 @@ To fix this you need to:
       kubectl create clusterrolebinding cluster-admin-binding \
         --clusterrole=cluster-admin \
         --user=<install-user>
+    or bind the least privileges the bundle needs, printed by the rbac custom action
  3. Re-install the bundle
 
 `)
@@ -75,6 +79,9 @@ To fix this you need to:
 		}
 		return true, nil
 	})
+	if err == nil {
+		resourceEvent(EventResourceApplied, res.Name).WithField(ATTEMPTS_FIELD, attempts).Infof("applied %s after %d attempts", res.Name, attempts)
+	}
 	if err == wait.ErrWaitTimeout {
 		return errors.New(fmt.Sprintf("could not create resource: %s", res.Name))
 	}
@@ -83,7 +90,9 @@ To fix this you need to:
 
 func (rm *rm) Check(res v1alpha1.KabResource, backOffSettings wait.Backoff) error {
 	for _, check := range res.Checks {
+		attempts := 0
 		err := wait.ExponentialBackoff(backOffSettings, func() (bool, error) {
+			attempts++
 			var ready bool
 			var innerErr error
 			ready, innerErr = rm.IsResourceReady(check)
@@ -95,13 +104,41 @@ func (rm *rm) Check(res v1alpha1.KabResource, backOffSettings wait.Backoff) erro
 			}
 			return true, nil
 		})
+		checkLog := resourceEvent(EventCheckPassed, res.Name).WithFields(log.Fields{
+			CHECK_FIELD:    checkName(check),
+			ATTEMPTS_FIELD: attempts,
+		})
+		if err != nil {
+			checkLog.WithField(EVENT_FIELD, EventCheckFailed).Warnf("check of %s failed after %d attempts", res.Name, attempts)
+		}
 		if err == wait.ErrWaitTimeout {
-			return errors.New(fmt.Sprintf("resource %s did not initialize", res.Name))
+			return &checkTimeoutError{resource: res.Name}
 		}
 		if err != nil {
 			return err
 		}
+		checkLog.Infof("check of %s passed after %d attempts", res.Name, attempts)
 	}
-	log.Infof("done installing %s", res.Name)
+	resourceEvent(EventResourceDone, res.Name).Infof("done installing %s", res.Name)
 	return nil
 }
+
+// DryRun submits the resource content to the api server with server-side dry-run and
+// returns the kubectl output describing what would have been created or changed
+func (rm *rm) DryRun(res v1alpha1.KabResource) (string, error) {
+	if res.Content == "" {
+		return "", errors.New(fmt.Sprintf("resource %s does not have Content for installation", res.Name))
+	}
+	installContent := []byte(res.Content)
+	log.Debugf("dry-run installing %s...", res.Name)
+	return rm.kubectl.ExecStdin([]string{"apply", "--dry-run=server", "-f", "-"}, &installContent)
+}
+
+// checkTimeoutError is returned when the checks of a resource did not pass in time
+type checkTimeoutError struct {
+	resource string
+}
+
+func (e *checkTimeoutError) Error() string {
+	return fmt.Sprintf("resource %s did not initialize", e.resource)
+}
@@ -386,6 +386,57 @@ spec:
 				})
 			})
 
+			Context("When the check reads a condition of the pod with a jsonpath", func() {
+				It("the check succeeds once the condition matches", func() {
+					mockKubeClient.On("CoreV1").Return(mockCore)
+					mockCore.On("Pods", mock.Anything).Return(mockPods)
+					mockPods.On("List", mock.Anything).Return(&corev1.PodList{
+						Items: []corev1.Pod{
+							{
+								ObjectMeta: metav1.ObjectMeta{
+									Labels: map[string]string{"istio": "sidecar-injector"},
+								},
+								Status: corev1.PodStatus{
+									Phase:      "Running",
+									Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}},
+								},
+							},
+						},
+					}, nil).Once()
+					mockPods.On("List", mock.Anything).Return(&corev1.PodList{
+						Items: []corev1.Pod{
+							{
+								ObjectMeta: metav1.ObjectMeta{
+									Labels: map[string]string{"istio": "sidecar-injector"},
+								},
+								Status: corev1.PodStatus{
+									Phase:      "Running",
+									Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+								},
+							},
+						},
+					}, nil)
+
+					resMan := kab.NewResourceManager(nil, mockKubeClient)
+					resource := v1alpha1.KabResource{
+						Name: "r1",
+						Checks: []v1alpha1.ResourceChecks{
+							{
+								Kind:     "Pod",
+								JsonPath: `.status.conditions[?(@.type=="Ready")].status`,
+								Selector: metav1.LabelSelector{
+									MatchLabels: map[string]string{"istio": "sidecar-injector"},
+								},
+								Pattern: "True",
+							},
+						},
+					}
+					err = resMan.Check(resource, backoffSettings)
+					Expect(err).To(BeNil())
+					mockPods.AssertNumberOfCalls(GinkgoT(), "List", 2)
+				})
+			})
+
 			Context("When the pod is found and the status is the desired status", func() {
 				It("the check succeeds", func() {
 					mockKubeClient.On("CoreV1").Return(mockCore)
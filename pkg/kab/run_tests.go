@@ -0,0 +1,152 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+// TestResult is the outcome of running a test resource of an installation
+type TestResult struct {
+	Name    string
+	Passed  bool
+	Details string
+}
+
+// Test runs the test resources of the installation in a namespace created for the run. Every test
+// is applied and its jobs and pods are awaited until they complete, their logs are written to the
+// installer output. The namespace and everything in it is deleted afterwards. A test which fails
+// does not stop the others, an error is only returned when the tests cannot be run.
+func (c *Client) Test(name string) ([]TestResult, error) {
+	manifest, err := c.LookupManifest(name)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to lookup manifest: %v", err))
+	}
+	results := []TestResult{}
+	tests := []v1alpha1.KabResource{}
+	for _, resource := range manifest.Spec.Resources {
+		if resource.Test {
+			tests = append(tests, resource)
+		}
+	}
+	if len(tests) == 0 {
+		log.Infof("installation %s has no tests", name)
+		return results, nil
+	}
+
+	namespace, err := c.createTestNamespace(name)
+	if err != nil {
+		return nil, err
+	}
+	defer c.deleteTestNamespace(namespace)
+
+	for _, test := range tests {
+		resourceEvent(EventTestStarted, test.Name).Infof("running test %s...", test.Name)
+		err = c.runTest(test, namespace)
+		if err != nil {
+			resourceEvent(EventTestFailed, test.Name).Warnf("test %s failed: %v", test.Name, err)
+			c.event(manifest, corev1.EventTypeWarning, ReasonTestFailed, "Test %s failed: %v", test.Name, err)
+			results = append(results, TestResult{Name: test.Name, Details: err.Error()})
+			continue
+		}
+		resourceEvent(EventTestPassed, test.Name).Infof("test %s passed", test.Name)
+		c.event(manifest, corev1.EventTypeNormal, ReasonTestPassed, "Test %s passed", test.Name)
+		results = append(results, TestResult{Name: test.Name, Passed: true})
+	}
+	return results, nil
+}
+
+func (c *Client) createTestNamespace(name string) (string, error) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		// installations into a target namespace are named <name>.<namespace>
+		Name:   fmt.Sprintf("%s-test-%s", strings.Replace(name, ".", "-", -1), utilrand.String(5)),
+		Labels: map[string]string{LABEL_KEY_NAME: name},
+	}}
+	_, err := c.coreClient.CoreV1().Namespaces().Create(namespace)
+	if err != nil {
+		return "", fmt.Errorf("error creating the namespace of the tests: %v", err)
+	}
+	log.Debugf("running the tests in namespace %s", namespace.Name)
+	return namespace.Name, nil
+}
+
+func (c *Client) deleteTestNamespace(namespace string) {
+	err := c.coreClient.CoreV1().Namespaces().Delete(namespace, &metav1.DeleteOptions{})
+	if err != nil && !k8serr.IsNotFound(err) {
+		log.Warnf("could not delete the namespace %s of the tests: %v", namespace, err)
+	}
+}
+
+// runTest applies the test in the namespace and waits for its jobs and pods to complete
+func (c *Client) runTest(test v1alpha1.KabResource, namespace string) error {
+	objects, err := scan.ListObjectsFromContent([]byte(test.Content))
+	if err != nil {
+		return err
+	}
+	content := []byte(test.Content)
+	out, err := c.kubectl.ExecStdin([]string{"apply", "-n", namespace, "-f", "-"}, &content)
+	log.Debugln(out)
+	if err != nil {
+		return fmt.Errorf("error applying the test: %v, due to: %s", err, out)
+	}
+	for i := range objects {
+		objects[i].SetNamespace(namespace)
+	}
+	return c.awaitWorkloads(resourceEvent(EventTestLog, test.Name), objects)
+}
+
+// TestsPassed returns true when every test passed
+func TestsPassed(results []TestResult) bool {
+	for _, result := range results {
+		if !result.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTestResults writes the results as a table with one row per test
+func WriteTestResults(out io.Writer, results []TestResult) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	_, err := fmt.Fprintln(w, "TEST\tRESULT\tDETAILS")
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		state := "passed"
+		if !result.Passed {
+			state = "failed"
+		}
+		_, err = fmt.Fprintf(w, "%s\t%s\t%s\n", result.Name, state, result.Details)
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
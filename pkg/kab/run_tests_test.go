@@ -0,0 +1,183 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/client/clientset/versioned/fake"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	mockkubectl "github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl/mocks"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/testing"
+)
+
+var _ = Describe("Test", func() {
+
+	const (
+		jobTest = "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: smoke\n"
+		podTest = "apiVersion: v1\nkind: Pod\nmetadata:\n  name: ping\n"
+	)
+
+	var (
+		client         *kab.Client
+		fakeKubeClient *kubefake.Clientset
+		mockKubectl    *mockkubectl.KubeCtl
+		manifest       *v1alpha1.Manifest
+		podPhase       v1.PodPhase
+		results        []kab.TestResult
+		logs           *bytes.Buffer
+		err            error
+	)
+
+	BeforeEach(func() {
+		podPhase = v1.PodSucceeded
+		logs = &bytes.Buffer{}
+		log.SetOutput(logs)
+		manifest = &v1alpha1.Manifest{
+			ObjectMeta: metav1.ObjectMeta{Name: "riff"},
+			Spec: v1alpha1.KabSpec{Resources: []v1alpha1.KabResource{
+				{Name: "app", Content: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n"},
+				{Name: "smoke", Content: jobTest, Test: true},
+				{Name: "ping", Content: podTest, Test: true},
+			}},
+		}
+		mockKubectl = new(mockkubectl.KubeCtl)
+		mockKubectl.On("ExecStdin", mock.Anything, mock.Anything).Return("", nil)
+	})
+
+	AfterEach(func() {
+		log.SetOutput(os.Stderr)
+	})
+
+	JustBeforeEach(func() {
+		fakeKubeClient = kubefake.NewSimpleClientset(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+		// the objects of the tests are found in whichever namespace they are looked up
+		fakeKubeClient.PrependReactor("get", "jobs", func(action testing.Action) (bool, runtime.Object, error) {
+			return true, &batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobComplete, Status: v1.ConditionTrue},
+			}}}, nil
+		})
+		fakeKubeClient.PrependReactor("get", "pods", func(action testing.Action) (bool, runtime.Object, error) {
+			return true, &v1.Pod{Status: v1.PodStatus{Phase: podPhase, Message: "ping timed out"}}, nil
+		})
+		fakeKubeClient.PrependReactor("list", "pods", func(action testing.Action) (bool, runtime.Object, error) {
+			return true, &v1.PodList{Items: []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Namespace: action.GetNamespace(), Name: "smoke-abcde", Labels: map[string]string{"job-name": "smoke"}}}}}, nil
+		})
+		fakeKabClient := fake.NewSimpleClientset()
+		fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+			return true, manifest.DeepCopy(), nil
+		})
+		client = kab.NewKnbClient(&logsClientset{Clientset: fakeKubeClient, logs: "ok\n"}, nil, fakeKabClient, nil, mockKubectl)
+		results, err = client.Test("riff")
+	})
+
+	// testNamespaces returns the namespaces created and deleted by the run
+	testNamespaces := func(verb string) []string {
+		namespaces := []string{}
+		for _, action := range fakeKubeClient.Actions() {
+			if action.GetVerb() != verb || action.GetResource().Resource != "namespaces" {
+				continue
+			}
+			switch a := action.(type) {
+			case testing.CreateAction:
+				namespaces = append(namespaces, a.GetObject().(*v1.Namespace).Name)
+			case testing.DeleteAction:
+				namespaces = append(namespaces, a.GetName())
+			}
+		}
+		return namespaces
+	}
+
+	Context("when the tests pass", func() {
+		It("runs them in a namespace which is deleted afterwards", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results).To(Equal([]kab.TestResult{{Name: "smoke", Passed: true}, {Name: "ping", Passed: true}}))
+			Expect(kab.TestsPassed(results)).To(BeTrue())
+
+			created := testNamespaces("create")
+			Expect(created).To(HaveLen(1))
+			Expect(created[0]).To(HavePrefix("riff-test-"))
+			Expect(testNamespaces("delete")).To(Equal(created))
+
+			Expect(mockKubectl.Calls).To(HaveLen(2))
+			Expect(mockKubectl.Calls[0].Arguments.Get(0)).To(Equal([]string{"apply", "-n", created[0], "-f", "-"}))
+			Expect(string(*mockKubectl.Calls[1].Arguments.Get(1).(*[]byte))).To(Equal(podTest))
+		})
+
+		It("writes the logs of the tests to the output", func() {
+			Expect(logs.String()).To(ContainSubstring(`msg=ok event=test_log pod=smoke-abcde resource=smoke`))
+			Expect(logs.String()).To(ContainSubstring(`msg=ok event=test_log pod=ping resource=ping`))
+		})
+	})
+
+	Context("when a test fails", func() {
+		BeforeEach(func() {
+			podPhase = v1.PodFailed
+		})
+
+		It("reports it and cleans up", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results).To(HaveLen(2))
+			Expect(results[0].Passed).To(BeTrue())
+			Expect(results[1].Passed).To(BeFalse())
+			Expect(results[1].Details).To(MatchRegexp(`^pod riff-test-\w+/ping failed: ping timed out$`))
+			Expect(kab.TestsPassed(results)).To(BeFalse())
+			Expect(testNamespaces("delete")).To(HaveLen(1))
+
+			out := &bytes.Buffer{}
+			Expect(kab.WriteTestResults(out, results)).To(Succeed())
+			Expect(strings.Split(out.String(), "\n")[1]).To(Equal("smoke   passed   "))
+		})
+	})
+
+	Context("when a test cannot be applied", func() {
+		BeforeEach(func() {
+			mockKubectl = new(mockkubectl.KubeCtl)
+			mockKubectl.On("ExecStdin", mock.Anything, mock.Anything).Return("forbidden", errors.New("exit status 1"))
+		})
+
+		It("the test fails", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results[0].Details).To(Equal("error applying the test: exit status 1, due to: forbidden"))
+		})
+	})
+
+	Context("when the installation has no tests", func() {
+		BeforeEach(func() {
+			manifest.Spec.Resources = manifest.Spec.Resources[:1]
+		})
+
+		It("no namespace is created", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results).To(BeEmpty())
+			Expect(testNamespaces("create")).To(BeEmpty())
+		})
+	})
+})
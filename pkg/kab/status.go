@@ -0,0 +1,136 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+)
+
+const (
+	StateReady    = "ready"
+	StateNotReady = "not ready"
+	StateMissing  = "missing"
+	StateDeferred = "deferred"
+	StateHook     = "hook"
+	StateTest     = "test"
+)
+
+type ResourceStatus struct {
+	Name    string
+	State   string
+	Details string
+}
+
+// Status looks up the installation and reports the health of each of its resources. Every object
+// of a resource must exist in the cluster and every check of the resource must pass, checks are
+// run once without retries.
+func (c *Client) Status(name string) ([]ResourceStatus, error) {
+	manifest, err := c.LookupManifest(name)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to lookup manifest: %v", err))
+	}
+	rm := NewResourceManager(c.kubectl, c.coreClient)
+	statuses := []ResourceStatus{}
+	for _, resource := range manifest.Spec.Resources {
+		status, err := c.resourceStatus(rm, manifest.Status, resource)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (c *Client) resourceStatus(rm *rm, installation v1alpha1.KabStatus, resource v1alpha1.KabResource) (ResourceStatus, error) {
+	if resource.Hook != "" {
+		return ResourceStatus{Name: resource.Name, State: StateHook}, nil
+	}
+	if resource.Test {
+		return ResourceStatus{Name: resource.Name, State: StateTest}, nil
+	}
+	if !installation.IsInstalled(resource) {
+		return ResourceStatus{Name: resource.Name, State: StateDeferred}, nil
+	}
+	objects, err := scan.ListObjectsFromContent([]byte(resource.Content))
+	if err != nil {
+		return ResourceStatus{}, err
+	}
+	missing := []string{}
+	for _, obj := range objects {
+		live, err := c.getLiveObject(obj)
+		if err != nil {
+			return ResourceStatus{}, err
+		}
+		if live == nil {
+			missing = append(missing, objectName(obj))
+		}
+	}
+	if len(missing) > 0 {
+		return ResourceStatus{Name: resource.Name, State: StateMissing, Details: strings.Join(missing, ", ")}, nil
+	}
+	for _, check := range resource.Checks {
+		ready, err := rm.IsResourceReady(check)
+		if err != nil {
+			return ResourceStatus{Name: resource.Name, State: StateNotReady, Details: err.Error()}, nil
+		}
+		if !ready {
+			return ResourceStatus{Name: resource.Name, State: StateNotReady, Details: describeCheck(check)}, nil
+		}
+	}
+	return ResourceStatus{Name: resource.Name, State: StateReady}, nil
+}
+
+func describeCheck(check v1alpha1.ResourceChecks) string {
+	return fmt.Sprintf("%s is not %s", checkName(check), check.Pattern)
+}
+
+func checkName(check v1alpha1.ResourceChecks) string {
+	return fmt.Sprintf("%s %s in namespace %q", check.Kind, convertMapToString(check.Selector.MatchLabels), check.Namespace)
+}
+
+// IsHealthy returns true when no resource is missing or not ready
+func IsHealthy(statuses []ResourceStatus) bool {
+	for _, status := range statuses {
+		if status.State != StateReady && status.State != StateDeferred && status.State != StateHook && status.State != StateTest {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteStatusTable writes the statuses as a table with one row per resource
+func WriteStatusTable(out io.Writer, statuses []ResourceStatus) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	_, err := fmt.Fprintln(w, "RESOURCE\tSTATUS\tDETAILS")
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		_, err = fmt.Fprintf(w, "%s\t%s\t%s\n", status.Name, status.State, status.Details)
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
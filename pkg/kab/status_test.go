@@ -0,0 +1,180 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/client/clientset/versioned/fake"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab/vendor_mocks"
+	mockkubectl "github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl/mocks"
+	"github.com/stretchr/testify/mock"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/testing"
+)
+
+var _ = Describe("Status Tests", func() {
+
+	const configMap = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: riff-system
+`
+	const liveConfigMap = `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config", "namespace": "riff-system"}}`
+
+	var (
+		client         *kab.Client
+		mockKubeClient *vendor_mocks.Interface
+		mockCore       *vendor_mocks.CoreV1Interface
+		mockNamespace  *vendor_mocks.NamespaceInterface
+		mockPods       *vendor_mocks.PodInterface
+		fakeKabClient  *fake.Clientset
+		mockKubectl    *mockkubectl.KubeCtl
+		manifest       *v1alpha1.Manifest
+		statuses       []kab.ResourceStatus
+		err            error
+	)
+
+	BeforeEach(func() {
+		mockKubeClient = new(vendor_mocks.Interface)
+		mockCore = new(vendor_mocks.CoreV1Interface)
+		mockNamespace = new(vendor_mocks.NamespaceInterface)
+		mockPods = new(vendor_mocks.PodInterface)
+		fakeKabClient = fake.NewSimpleClientset()
+		mockKubectl = new(mockkubectl.KubeCtl)
+		mockKubeClient.On("CoreV1").Return(mockCore)
+		mockCore.On("Namespaces").Return(mockNamespace)
+		mockCore.On("Pods", mock.Anything).Return(mockPods)
+		mockNamespace.On("List", mock.Anything).Return(&v12.NamespaceList{
+			Items: []v12.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "default"}}},
+		}, nil)
+
+		manifest = &v1alpha1.Manifest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myInstall",
+			},
+			Spec: v1alpha1.KabSpec{
+				Resources: []v1alpha1.KabResource{
+					{
+						Name:    "res1",
+						Content: configMap,
+						Checks: []v1alpha1.ResourceChecks{
+							{
+								Kind:      "Pod",
+								Namespace: "riff-system",
+								Selector: metav1.LabelSelector{
+									MatchLabels: map[string]string{"app": "controller"},
+								},
+								Pattern: "Running",
+							},
+						},
+					},
+					{
+						Name:     "res2",
+						Deferred: true,
+					},
+				},
+			},
+		}
+		fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+			return true, manifest, nil
+		})
+
+		client = kab.NewKnbClient(mockKubeClient, nil, fakeKabClient, nil, mockKubectl)
+	})
+
+	Context("when all objects exist and the checks pass", func() {
+		It("the resources are ready", func() {
+			mockKubectl.On("ExecStdin", []string{"get", "-f", "-", "-o", "json", "--ignore-not-found"}, mock.Anything).Return(liveConfigMap, nil)
+			mockPods.On("List", mock.Anything).Return(&v12.PodList{
+				Items: []v12.Pod{{Status: v12.PodStatus{Phase: "Running"}}},
+			}, nil).Once()
+
+			statuses, err = client.Status("myInstall")
+			Expect(err).To(BeNil())
+			Expect(statuses).To(Equal([]kab.ResourceStatus{
+				{Name: "res1", State: kab.StateReady},
+				{Name: "res2", State: kab.StateDeferred},
+			}))
+			Expect(kab.IsHealthy(statuses)).To(BeTrue())
+		})
+	})
+
+	Context("when a deferred resource was installed", func() {
+		It("the resource is checked like the others", func() {
+			manifest.Status.InstalledDeferred = []string{"res2"}
+			mockKubectl.On("ExecStdin", []string{"get", "-f", "-", "-o", "json", "--ignore-not-found"}, mock.Anything).Return(liveConfigMap, nil)
+			mockPods.On("List", mock.Anything).Return(&v12.PodList{
+				Items: []v12.Pod{{Status: v12.PodStatus{Phase: "Running"}}},
+			}, nil).Once()
+
+			statuses, err = client.Status("myInstall")
+			Expect(err).To(BeNil())
+			Expect(statuses[1]).To(Equal(kab.ResourceStatus{Name: "res2", State: kab.StateReady}))
+		})
+	})
+
+	Context("when a check does not pass", func() {
+		It("the check is run only once and the resource is not ready", func() {
+			mockKubectl.On("ExecStdin", []string{"get", "-f", "-", "-o", "json", "--ignore-not-found"}, mock.Anything).Return(liveConfigMap, nil)
+			mockPods.On("List", mock.Anything).Return(&v12.PodList{
+				Items: []v12.Pod{{Status: v12.PodStatus{Phase: "Pending"}}},
+			}, nil).Once()
+
+			statuses, err = client.Status("myInstall")
+			Expect(err).To(BeNil())
+			Expect(statuses[0].State).To(Equal(kab.StateNotReady))
+			Expect(statuses[0].Details).To(Equal(`Pod app=controller in namespace "riff-system" is not Running`))
+			Expect(kab.IsHealthy(statuses)).To(BeFalse())
+			mockPods.AssertExpectations(GinkgoT())
+		})
+	})
+
+	Context("when an object no longer exists", func() {
+		It("the resource is missing", func() {
+			mockKubectl.On("ExecStdin", []string{"get", "-f", "-", "-o", "json", "--ignore-not-found"}, mock.Anything).Return("", nil)
+
+			statuses, err = client.Status("myInstall")
+			Expect(err).To(BeNil())
+			Expect(statuses[0]).To(Equal(kab.ResourceStatus{Name: "res1", State: kab.StateMissing, Details: "configmap/riff-system/config"}))
+			Expect(kab.IsHealthy(statuses)).To(BeFalse())
+			Expect(mockPods.Calls).To(BeEmpty())
+		})
+	})
+
+	Describe("WriteStatusTable", func() {
+		It("writes a row per resource", func() {
+			out := &bytes.Buffer{}
+			err = kab.WriteStatusTable(out, []kab.ResourceStatus{
+				{Name: "istio", State: kab.StateReady},
+				{Name: "riff", State: kab.StateMissing, Details: "namespace/riff-system"},
+			})
+			Expect(err).To(BeNil())
+			Expect(out.String()).To(HavePrefix("RESOURCE   STATUS    DETAILS\n"))
+			Expect(out.String()).To(ContainSubstring("\nistio      ready"))
+			Expect(out.String()).To(ContainSubstring("\nriff       missing   namespace/riff-system\n"))
+		})
+	})
+})
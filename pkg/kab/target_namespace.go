@@ -0,0 +1,250 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const TARGET_NAMESPACE_ENV_VAR = "TARGET_NAMESPACE"
+
+// namespaceReferences are the fields of cluster scoped kinds which reference a namespaced object,
+// keyed by group/Kind. A path segment of "*" iterates a list.
+var namespaceReferences = map[string][][]string{
+	"admissionregistration.k8s.io/MutatingWebhookConfiguration":   {{"webhooks", "*", "clientConfig", "service", "namespace"}},
+	"admissionregistration.k8s.io/ValidatingWebhookConfiguration": {{"webhooks", "*", "clientConfig", "service", "namespace"}},
+	"apiregistration.k8s.io/APIService":                           {{"spec", "service", "namespace"}},
+	"apiextensions.k8s.io/CustomResourceDefinition": {
+		{"spec", "conversion", "webhookClientConfig", "service", "namespace"},
+		{"spec", "conversion", "webhook", "clientConfig", "service", "namespace"},
+	},
+	"rbac.authorization.k8s.io/RoleBinding":        {{"subjects", "*", "namespace"}},
+	"rbac.authorization.k8s.io/ClusterRoleBinding": {{"subjects", "*", "namespace"}},
+}
+
+// GetTargetNamespace returns the namespace the namespaced objects of the bundle are installed into,
+// or an empty string to install them into the namespaces of the bundle
+func GetTargetNamespace() string {
+	return os.Getenv(TARGET_NAMESPACE_ENV_VAR)
+}
+
+// retargetManifest moves the namespaced objects of the resources into the target namespace, along
+// with the references to the namespaces of the bundle: the subjects of role bindings, the services
+// of webhooks, the namespaces of checks and outputs. Namespace objects of the resources are replaced
+// by the target namespace, declared with their labels and annotations and the declared ones, and
+// the resources left without objects are removed. Test resources keep their namespace, since they
+// run in a namespace of their own.
+func (c *Client) retargetManifest(manifest *v1alpha1.Manifest, target string) error {
+	objects := map[int][]unstructured.Unstructured{}
+	all := []unstructured.Unstructured{}
+	for i, resource := range manifest.Spec.Resources {
+		if resource.Test {
+			continue
+		}
+		resourceObjects, err := scan.ListObjectsFromContent([]byte(resource.Content))
+		if err != nil {
+			return fmt.Errorf("error scanning resource %s: %v", resource.Name, err)
+		}
+		objects[i] = resourceObjects
+		all = append(all, resourceObjects...)
+	}
+	crds := c.kindScopes(all)
+
+	// the namespaces of the bundle, which are all replaced by the target namespace
+	namespaces := map[string]bool{"": true}
+	targetNamespace := v1alpha1.KabNamespace{Name: target}
+	for _, declared := range manifest.Spec.Namespaces {
+		namespaces[declared.Name] = true
+		targetNamespace.Labels = mergeStrings(targetNamespace.Labels, declared.Labels)
+		targetNamespace.Annotations = mergeStrings(targetNamespace.Annotations, declared.Annotations)
+	}
+	for _, obj := range all {
+		if obj.GetAPIVersion() == "v1" && obj.GetKind() == "Namespace" {
+			namespaces[obj.GetName()] = true
+			targetNamespace.Labels = mergeStrings(targetNamespace.Labels, obj.GetLabels())
+			targetNamespace.Annotations = mergeStrings(targetNamespace.Annotations, obj.GetAnnotations())
+		} else if isNamespaced(obj, crds) {
+			namespaces[obj.GetNamespace()] = true
+		}
+	}
+	delete(targetNamespace.Labels, LABEL_KEY_NAME)
+	manifest.Spec.Namespaces = []v1alpha1.KabNamespace{targetNamespace}
+
+	removed := map[int]bool{}
+	for i, resourceObjects := range objects {
+		resource := &manifest.Spec.Resources[i]
+		docs := []string{}
+		for _, obj := range resourceObjects {
+			if obj.GetAPIVersion() == "v1" && obj.GetKind() == "Namespace" {
+				continue
+			}
+			if isNamespaced(obj, crds) {
+				obj.SetNamespace(target)
+			}
+			retargetReferences(obj.Object, namespaceReferences[objectGroupKind(obj)], namespaces, target)
+			content, err := yaml.Marshal(obj.Object)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, string(content))
+		}
+		if len(docs) == 0 {
+			log.Debugf("removing resource %s, which only has namespaces", resource.Name)
+			removed[i] = true
+			continue
+		}
+		resource.Content = strings.Join(docs, "---\n")
+		for j := range resource.Checks {
+			if namespaces[resource.Checks[j].Namespace] {
+				resource.Checks[j].Namespace = target
+			}
+		}
+	}
+	resources := []v1alpha1.KabResource{}
+	for i, resource := range manifest.Spec.Resources {
+		if !removed[i] {
+			resources = append(resources, resource)
+		}
+	}
+	manifest.Spec.Resources = resources
+	for i := range manifest.Spec.Outputs {
+		if namespaces[manifest.Spec.Outputs[i].Namespace] {
+			manifest.Spec.Outputs[i].Namespace = target
+		}
+	}
+	return nil
+}
+
+// retargetReferences replaces the namespaces of the bundle found at the paths of the object
+func retargetReferences(obj map[string]interface{}, paths [][]string, namespaces map[string]bool, target string) {
+	for _, path := range paths {
+		retargetReference(obj, path, namespaces, target)
+	}
+}
+
+func retargetReference(value interface{}, path []string, namespaces map[string]bool, target string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			if namespace, ok := v[path[0]].(string); ok && namespaces[namespace] {
+				v[path[0]] = target
+			}
+			return
+		}
+		retargetReference(v[path[0]], path[1:], namespaces, target)
+	case []interface{}:
+		if path[0] != "*" {
+			return
+		}
+		for _, item := range v {
+			retargetReference(item, path[1:], namespaces, target)
+		}
+	}
+}
+
+// checkClusterScopedObjects returns an error when cluster scoped objects of the manifest already
+// exist for another installation. The copies of a bundle installed into different target
+// namespaces cannot share them, since each copy would change or delete the objects of the others.
+func (c *Client) checkClusterScopedObjects(manifest *v1alpha1.Manifest) error {
+	all := []unstructured.Unstructured{}
+	for _, resource := range manifest.Spec.Resources {
+		if resource.Test {
+			continue
+		}
+		resourceObjects, err := scan.ListObjectsFromContent([]byte(resource.Content))
+		if err != nil {
+			return fmt.Errorf("error scanning resource %s: %v", resource.Name, err)
+		}
+		all = append(all, resourceObjects...)
+	}
+	crds := c.kindScopes(all)
+	collisions := []string{}
+	for _, obj := range all {
+		if isNamespaced(obj, crds) {
+			continue
+		}
+		live, err := c.getLiveObject(obj)
+		if err != nil {
+			return err
+		}
+		if live == nil {
+			continue
+		}
+		if owner := live.GetLabels()[LABEL_KEY_NAME]; owner != GetInstallationName() {
+			collisions = append(collisions, fmt.Sprintf("%s (installation %q)", objectName(obj), owner))
+		}
+	}
+	if len(collisions) > 0 {
+		return errors.New(fmt.Sprintf("cluster scoped objects already exist for other installations: %s", strings.Join(collisions, ", ")))
+	}
+	return nil
+}
+
+// kindScopes returns the resources defined by the CRDs of the objects, and those served by the
+// cluster when the client is connected to one, keyed by group/Kind. The CRDs of the objects win
+// over the cluster, which may serve an older version of them.
+func (c *Client) kindScopes(objects []unstructured.Unstructured) map[string]crdInfo {
+	crds := manifestCRDs(objects)
+	if c.coreClient == nil {
+		return crds
+	}
+	// the resources of the groups which could be discovered are returned along with the error
+	_, lists, err := c.coreClient.Discovery().ServerGroupsAndResources()
+	if err != nil {
+		log.Debugf("could not discover all the resources of the cluster: %v", err)
+	}
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			key := gv.Group + "/" + resource.Kind
+			if _, ok := crds[key]; !ok && !strings.Contains(resource.Name, "/") {
+				crds[key] = crdInfo{resource: resource.Name, namespaced: resource.Namespaced}
+			}
+		}
+	}
+	return crds
+}
+
+// isNamespaced returns true when the kind of the object is namespaced. The scope of custom
+// resources is read from the CRDs of the manifest, and the scope of the other kinds from the
+// cluster, falling back to the built-in cluster scoped kinds when it is unknown.
+func isNamespaced(obj unstructured.Unstructured, crds map[string]crdInfo) bool {
+	key := objectGroupKind(obj)
+	if crd, ok := crds[key]; ok {
+		return crd.namespaced
+	}
+	return !clusterScopedKinds[key]
+}
+
+// objectGroupKind returns the group/Kind key of the object
+func objectGroupKind(obj unstructured.Unstructured) string {
+	gv, _ := schema.ParseGroupVersion(obj.GetAPIVersion())
+	return gv.Group + "/" + obj.GetKind()
+}
@@ -0,0 +1,358 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/client/clientset/versioned/fake"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	vendor_mocks_ext "github.com/projectriff/cnab-k8s-installer-base/pkg/kab/vendor_mocks/ext"
+	mockkubectl "github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl/mocks"
+	mockkustomize "github.com/projectriff/cnab-k8s-installer-base/pkg/kustomize/mocks"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/testing"
+)
+
+var _ = Describe("Target namespace", func() {
+
+	const (
+		namespace = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: riff-system
+  labels:
+    team: riff
+`
+		controller = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+  namespace: riff-system
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+		rbac = `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: riff-controller
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: riff-controller
+subjects:
+- kind: ServiceAccount
+  name: controller
+  namespace: riff-system
+- kind: ServiceAccount
+  name: dns
+  namespace: kube-system
+`
+		webhook = `apiVersion: admissionregistration.k8s.io/v1beta1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: riff-webhook
+webhooks:
+- name: validate.projectriff.io
+  clientConfig:
+    service:
+      name: webhook
+      namespace: riff-system
+`
+		test = `apiVersion: v1
+kind: Pod
+metadata:
+  name: ping
+`
+	)
+
+	var (
+		manifest *v1alpha1.Manifest
+		err      error
+	)
+
+	BeforeEach(func() {
+		os.Setenv(kab.CNAB_INSTALLATION_NAME_ENV_VAR, "riff")
+		os.Setenv(kab.TARGET_NAMESPACE_ENV_VAR, "tenant-a")
+		manifest = &v1alpha1.Manifest{
+			ObjectMeta: metav1.ObjectMeta{Name: "riff-install"},
+			Spec: v1alpha1.KabSpec{
+				Namespaces: []v1alpha1.KabNamespace{
+					{Name: "riff-system", Labels: map[string]string{"istio-injection": "enabled"}},
+				},
+				Resources: []v1alpha1.KabResource{
+					{Name: "namespace", Content: namespace},
+					{Name: "controller", Content: controller, Checks: []v1alpha1.ResourceChecks{{Kind: "Pod", Namespace: "riff-system"}}},
+					{Name: "rbac", Content: rbac},
+					{Name: "webhook", Content: webhook},
+					{Name: "ping", Content: test, Test: true},
+				},
+				Outputs: []v1alpha1.KabOutput{{Name: "config", Kind: "ConfigMap", Namespace: "riff-system", ObjectName: "config"}},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		os.Unsetenv(kab.CNAB_INSTALLATION_NAME_ENV_VAR)
+		os.Unsetenv(kab.TARGET_NAMESPACE_ENV_VAR)
+	})
+
+	It("keys the installation by the namespace", func() {
+		Expect(kab.GetInstallationName()).To(Equal("riff.tenant-a"))
+	})
+
+	Describe("PrepareManifest", func() {
+		resource := func(name string) v1alpha1.KabResource {
+			for _, res := range manifest.Spec.Resources {
+				if res.Name == name {
+					return res
+				}
+			}
+			Fail("no resource " + name)
+			return v1alpha1.KabResource{}
+		}
+		objects := func(name string) []unstructured.Unstructured {
+			objs, err := scan.ListObjectsFromContent([]byte(resource(name).Content))
+			Expect(err).NotTo(HaveOccurred())
+			return objs
+		}
+
+		BeforeEach(func() {
+			mockKustomize := new(mockkustomize.Kustomizer)
+			mockKustomize.On("ApplyLabels", mock.Anything, mock.Anything).Return(func(content string, labels map[string]string) []byte {
+				return []byte(content)
+			}, nil)
+			client := kab.NewKnbClient(nil, nil, nil, mockKustomize, nil)
+			err = client.PrepareManifest(manifest)
+		})
+
+		It("moves the namespaced objects into the target namespace", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest.Name).To(Equal("riff.tenant-a"))
+			controller := objects("controller")
+			Expect(controller).To(HaveLen(2))
+			Expect(controller[0].GetNamespace()).To(Equal("tenant-a"))
+			Expect(controller[1].GetNamespace()).To(Equal("tenant-a"))
+			Expect(resource("controller").Checks[0].Namespace).To(Equal("tenant-a"))
+			Expect(manifest.Spec.Outputs[0].Namespace).To(Equal("tenant-a"))
+		})
+
+		It("replaces the namespaces of the bundle by the target namespace, removing the resources left empty", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest.Spec.Resources).To(HaveLen(4))
+			Expect(manifest.Spec.Resources[0].Name).To(Equal("controller"))
+			Expect(manifest.Spec.Namespaces).To(Equal([]v1alpha1.KabNamespace{{
+				Name:        "tenant-a",
+				Labels:      map[string]string{"istio-injection": "enabled", "team": "riff"},
+				Annotations: map[string]string{},
+			}}))
+		})
+
+		It("fixes the references to the namespaces of the bundle", func() {
+			Expect(err).NotTo(HaveOccurred())
+			binding := objects("rbac")[0]
+			Expect(binding.GetNamespace()).To(BeEmpty())
+			subjects, _, _ := unstructured.NestedSlice(binding.Object, "subjects")
+			Expect(subjects[0].(map[string]interface{})["namespace"]).To(Equal("tenant-a"))
+			Expect(subjects[1].(map[string]interface{})["namespace"]).To(Equal("kube-system"))
+			webhooks, _, _ := unstructured.NestedSlice(objects("webhook")[0].Object, "webhooks")
+			service, _, _ := unstructured.NestedString(webhooks[0].(map[string]interface{}), "clientConfig", "service", "namespace")
+			Expect(service).To(Equal("tenant-a"))
+		})
+
+		It("leaves the tests in their namespace", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resource("ping").Content).To(Equal(test))
+		})
+	})
+
+	Describe("PrepareManifest with a cluster", func() {
+		const issuer = `apiVersion: cert-manager.io/v1
+kind: ClusterIssuer
+metadata:
+  name: riff-ca
+`
+
+		BeforeEach(func() {
+			manifest.Spec.Resources = append(manifest.Spec.Resources, v1alpha1.KabResource{Name: "issuer", Content: issuer})
+			mockKustomize := new(mockkustomize.Kustomizer)
+			mockKustomize.On("ApplyLabels", mock.Anything, mock.Anything).Return(func(content string, labels map[string]string) []byte {
+				return []byte(content)
+			}, nil)
+			// the CRD of the issuer was installed by another bundle
+			fakeKubeClient := kubefake.NewSimpleClientset()
+			fakeKubeClient.Resources = []*metav1.APIResourceList{{
+				GroupVersion: "cert-manager.io/v1",
+				APIResources: []metav1.APIResource{{Name: "clusterissuers", Kind: "ClusterIssuer", Namespaced: false}},
+			}}
+			client := kab.NewKnbClient(fakeKubeClient, nil, nil, mockKustomize, nil)
+			err = client.PrepareManifest(manifest)
+		})
+
+		It("reads the scope of the kinds the manifest does not define from the cluster", func() {
+			Expect(err).NotTo(HaveOccurred())
+			issuer := manifest.Spec.Resources[len(manifest.Spec.Resources)-1]
+			Expect(issuer.Name).To(Equal("issuer"))
+			objs, err := scan.ListObjectsFromContent([]byte(issuer.Content))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objs[0].GetNamespace()).To(BeEmpty())
+		})
+	})
+
+	Describe("Install", func() {
+		var (
+			mockKubectl    *mockkubectl.KubeCtl
+			fakeKubeClient *kubefake.Clientset
+		)
+
+		BeforeEach(func() {
+			manifest.Spec.Resources = manifest.Spec.Resources[:4]
+			manifest.Spec.Resources[1].Checks = nil
+			manifest.Spec.Outputs = nil
+			mockKubectl = new(mockkubectl.KubeCtl)
+			mockKubectl.On("ExecStdin", []string{"get", "-f", "-", "-o", "json", "--ignore-not-found"}, mock.Anything).Return("", nil)
+			mockKubectl.On("ExecStdin", []string{"apply", "-f", "-"}, mock.Anything).Return("", nil)
+			mockKustomize := new(mockkustomize.Kustomizer)
+			mockKustomize.On("ApplyLabels", mock.Anything, mock.Anything).Return(func(content string, labels map[string]string) []byte {
+				return []byte(content)
+			}, nil)
+			mockCrdi := new(vendor_mocks_ext.CustomResourceDefinitionInterface)
+			mockCrdi.On("Create", mock.Anything).Return(nil, nil)
+			mockExtensionInterface := new(vendor_mocks_ext.ApiextensionsV1beta1Interface)
+			mockExtensionInterface.On("CustomResourceDefinitions").Return(mockCrdi)
+			mockExtensionClientSet := new(vendor_mocks_ext.Interface)
+			mockExtensionClientSet.On("ApiextensionsV1beta1").Return(mockExtensionInterface)
+			fakeKabClient := fake.NewSimpleClientset()
+			fakeKabClient.PrependReactor("*", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				return true, nil, nil
+			})
+			fakeKubeClient = kubefake.NewSimpleClientset(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+			client := kab.NewKnbClient(fakeKubeClient, mockExtensionClientSet, fakeKabClient, mockKustomize, mockKubectl)
+			err = client.PrepareManifest(manifest)
+			Expect(err).NotTo(HaveOccurred())
+			err = client.Install(manifest)
+		})
+
+		It("installs the resources left into the target namespace", func() {
+			Expect(err).NotTo(HaveOccurred())
+			_, err = fakeKubeClient.CoreV1().Namespaces().Get("tenant-a", metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			applied := []string{}
+			for _, call := range mockKubectl.Calls {
+				if call.Arguments.Get(0).([]string)[0] == "apply" {
+					applied = append(applied, string(*call.Arguments.Get(1).(*[]byte)))
+				}
+			}
+			Expect(applied).To(HaveLen(3))
+			Expect(applied[0]).To(ContainSubstring("namespace: tenant-a"))
+		})
+	})
+
+	Describe("Uninstall", func() {
+		var (
+			mockKubectl    *mockkubectl.KubeCtl
+			fakeKubeClient *kubefake.Clientset
+		)
+
+		BeforeEach(func() {
+			manifest.Name = "riff.tenant-a"
+			manifest.Spec.Resources = manifest.Spec.Resources[1:3]
+			manifest.Spec.Namespaces = []v1alpha1.KabNamespace{{Name: "tenant-a"}}
+			mockKubectl = new(mockkubectl.KubeCtl)
+			mockKubectl.On("Exec", mock.Anything).Return("", nil)
+			fakeKabClient := fake.NewSimpleClientset()
+			fakeKabClient.PrependReactor("*", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				return true, manifest.DeepCopy(), nil
+			})
+			// the target namespace existed before the installation
+			fakeKubeClient = kubefake.NewSimpleClientset(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-a"}})
+			client := kab.NewKnbClient(fakeKubeClient, nil, fakeKabClient, nil, mockKubectl)
+			err = client.Uninstall(manifest.Name)
+		})
+
+		It("deletes the namespaced objects in the target namespace and keeps the namespace", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockKubectl.Calls).To(HaveLen(1))
+			args := mockKubectl.Calls[0].Arguments.Get(0).([]string)
+			Expect(args[0]).To(Equal("delete"))
+			Expect(args[2:]).To(Equal([]string{"-l", kab.LABEL_KEY_NAME + "=riff.tenant-a", "-n", "tenant-a"}))
+			_, err = fakeKubeClient.CoreV1().Namespaces().Get("tenant-a", metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("Upgrade", func() {
+		var mockKubectl *mockkubectl.KubeCtl
+
+		BeforeEach(func() {
+			manifest.Name = "riff.tenant-a"
+			manifest.Spec.Resources = manifest.Spec.Resources[2:3]
+			manifest.Spec.Outputs = nil
+			mockKubectl = new(mockkubectl.KubeCtl)
+			mockKubectl.On("ExecStdin", []string{"apply", "-f", "-"}, mock.Anything).Return("", nil)
+		})
+
+		JustBeforeEach(func() {
+			fakeKabClient := fake.NewSimpleClientset()
+			fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				return true, manifest.DeepCopy(), nil
+			})
+			fakeKabClient.PrependReactor("update", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				return true, action.(testing.UpdateAction).GetObject(), nil
+			})
+			fakeKubeClient := kubefake.NewSimpleClientset(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+			client := kab.NewKnbClient(fakeKubeClient, nil, fakeKabClient, nil, mockKubectl)
+			err = client.Upgrade(manifest)
+		})
+
+		Context("when the cluster scoped objects belong to another installation", func() {
+			BeforeEach(func() {
+				mockKubectl.On("ExecStdin", []string{"get", "-f", "-", "-o", "json", "--ignore-not-found"}, mock.Anything).
+					Return(`{"kind": "ClusterRoleBinding", "metadata": {"name": "riff-controller", "labels": {"cnab-k8s-installer-installation-name": "riff.tenant-b"}}}`, nil)
+			})
+
+			It("rejects them", func() {
+				Expect(err).To(MatchError(`Could not upgrade riff: cluster scoped objects already exist for other installations: clusterrolebinding/riff-controller (installation "riff.tenant-b") `))
+				mockKubectl.AssertNotCalled(GinkgoT(), "ExecStdin", []string{"apply", "-f", "-"}, mock.Anything)
+			})
+		})
+
+		Context("when the cluster scoped objects belong to the installation", func() {
+			BeforeEach(func() {
+				mockKubectl.On("ExecStdin", []string{"get", "-f", "-", "-o", "json", "--ignore-not-found"}, mock.Anything).
+					Return(`{"kind": "ClusterRoleBinding", "metadata": {"name": "riff-controller", "labels": {"cnab-k8s-installer-installation-name": "riff.tenant-a"}}}`, nil)
+			})
+
+			It("upgrades them", func() {
+				Expect(err).NotTo(HaveOccurred())
+				mockKubectl.AssertCalled(GinkgoT(), "ExecStdin", []string{"apply", "-f", "-"}, mock.Anything)
+			})
+		})
+	})
+})
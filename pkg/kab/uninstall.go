@@ -24,47 +24,84 @@ import (
 	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
 	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func (c *Client) Uninstall(name string) error {
-	var manifest *v1alpha1.Manifest
-	var err error
-	kindList := []string{}
-
-	manifest, err = c.LookupManifest(name)
+	manifest, err := c.LookupManifest(name)
 	if err != nil {
 		return e.New(fmt.Sprintf("unable to lookup manifest: %v", err))
 	}
-	for _, resource := range manifest.Spec.Resources {
-		kinds, err := scan.ListKindFromContent([]byte(resource.Content))
-		if err != nil {
-			return err
-		}
-		kindList = append(kindList, kinds...)
+	kindList, err := listKinds(manifest)
+	if err != nil {
+		return err
 	}
 	installationName := GetInstallationName()
 
 	log.Infof("uninstalling %s...\n", installationName)
+	c.event(manifest, corev1.EventTypeNormal, ReasonUninstallStarted, "Uninstalling %s", installationName)
 
-	label := LABEL_KEY_NAME + "=" + installationName
+	err = c.runHooks(manifest, v1alpha1.HookPreUninstall)
+	if err != nil {
+		c.event(manifest, corev1.EventTypeWarning, ReasonUninstallFailed, "Uninstall failed: %v", err)
+		return e.New(fmt.Sprintf("error while uninstalling: %v", err))
+	}
 
-	log.Debugf("Issuing kubectl delete %s -l %s\n", strings.Join(kindList, ","), label)
-	out, err := c.kubectl.Exec([]string{"delete", strings.Join(kindList, ","), "-l", label})
-	log.Debugf(out)
+	args := deleteArgs(kindList, installationName)
+	log.Debugf("Issuing kubectl %s\n", strings.Join(args, " "))
+	out, err := c.kubectl.Exec(args)
+	log.Debugln(out)
 	if err != nil {
+		c.event(manifest, corev1.EventTypeWarning, ReasonUninstallFailed, "Could not delete objects: %s", out)
 		return e.New(fmt.Sprintf("error while uninstalling: %v, due to: %s", err, out))
 	}
+	log.WithFields(log.Fields{EVENT_FIELD: EventObjectsDeleted, KINDS_FIELD: kindList}).Infof("deleted objects of %s", installationName)
+
+	err = c.runHooks(manifest, v1alpha1.HookPostUninstall)
+	if err == nil {
+		err = c.deleteNamespaces(manifest)
+	}
+	if err != nil {
+		c.event(manifest, corev1.EventTypeWarning, ReasonUninstallFailed, "Uninstall failed: %v", err)
+		return e.New(fmt.Sprintf("error while uninstalling: %v", err))
+	}
 
 	log.Infoln("uninstalling bundle manifest from cluster")
 	err = c.kabClient.ProjectriffV1alpha1().Manifests().Delete(manifest.Name, &metav1.DeleteOptions{})
 	if err != nil {
+		c.event(manifest, corev1.EventTypeWarning, ReasonUninstallFailed, "Could not delete the manifest: %v", err)
 		return e.New(fmt.Sprintf("error while deleting the manifest: %v", err))
 	}
+	c.event(manifest, corev1.EventTypeNormal, ReasonUninstallCompleted, "Uninstalled %s", installationName)
+	actionEvent(EventActionDone, "uninstall").Infof("%s uninstalled", installationName)
 	return nil
 }
 
+// deleteArgs returns the kubectl arguments deleting the objects of the installation. The namespaced
+// objects of an installation into a target namespace are deleted there, whatever the namespace of
+// the kubectl context.
+func deleteArgs(kindList []string, installationName string) []string {
+	args := []string{"delete", strings.Join(kindList, ","), "-l", LABEL_KEY_NAME + "=" + installationName}
+	if namespace := GetTargetNamespace(); namespace != "" {
+		args = append(args, "-n", namespace)
+	}
+	return args
+}
+
+func listKinds(manifest *v1alpha1.Manifest) ([]string, error) {
+	kindList := []string{}
+	for _, resource := range manifest.Spec.Resources {
+		kinds, err := scan.ListKindFromContent([]byte(resource.Content))
+		if err != nil {
+			return nil, err
+		}
+		kindList = append(kindList, kinds...)
+	}
+	return kindList, nil
+}
+
 func (c *Client) LookupManifest(name string) (*v1alpha1.Manifest, error) {
 	namespaceList, err := c.coreClient.CoreV1().Namespaces().List(metav1.ListOptions{})
 	if err != nil {
@@ -79,6 +116,10 @@ func (c *Client) LookupManifest(name string) (*v1alpha1.Manifest, error) {
 			return nil, err
 		}
 		manifest.Namespace = ns.Name
+		err = c.readSecretContent(manifest)
+		if err != nil {
+			return nil, err
+		}
 		return manifest, nil
 	}
 	return nil, e.New(fmt.Sprintf("could not find manifest for installation name: %s", name))
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Upgrade applies the resources of the manifest over an existing installation and
+// replaces the stored manifest with the new one. Deferred resources which were installed
+// on demand and are still deferred in the new manifest are upgraded as well.
+func (c *Client) Upgrade(manifest *v1alpha1.Manifest) error {
+	old, err := c.LookupManifest(manifest.Name)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to lookup manifest: %v", err))
+	}
+	if GetTargetNamespace() != "" {
+		err = c.checkClusterScopedObjects(manifest)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Could not upgrade riff: %s ", err))
+		}
+	}
+	log.Infoln("Upgrading bundle components")
+	log.Infoln()
+	manifest.UID = old.UID
+	manifest.ResourceVersion = old.ResourceVersion
+	manifest.Status.InstalledDeferred = keepInstalledDeferred(old, manifest)
+	manifest.Status.CreatedNamespaces = old.Status.CreatedNamespaces
+	c.event(manifest, corev1.EventTypeNormal, ReasonUpgradeStarted, "Upgrading %d resources", len(manifest.Spec.Resources))
+	err = c.createNamespaces(manifest, old)
+	if err == nil {
+		err = c.installWithHooks(manifest, v1alpha1.HookPreUpgrade, v1alpha1.HookPostUpgrade)
+	}
+	if err != nil {
+		c.event(manifest, corev1.EventTypeWarning, ReasonUpgradeFailed, "Upgrade failed: %v", err)
+		return errors.New(fmt.Sprintf("Could not upgrade riff: %s ", err))
+	}
+	_, err = c.kabClient.ProjectriffV1alpha1().Manifests().Update(storedManifest(manifest))
+	if err != nil {
+		c.event(manifest, corev1.EventTypeWarning, ReasonUpgradeFailed, "Could not update the manifest: %v", err)
+		return errors.New(fmt.Sprintf("error while updating the manifest: %v", err))
+	}
+	err = c.writeManifestOutputs(manifest, backOffSettings())
+	if err != nil {
+		return err
+	}
+	c.event(manifest, corev1.EventTypeNormal, ReasonUpgradeCompleted, "Upgrade completed")
+	actionEvent(EventActionDone, "upgrade").Infof("Kubernetes Application Bundle upgraded")
+	return nil
+}
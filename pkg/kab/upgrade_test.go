@@ -0,0 +1,138 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/client/clientset/versioned/fake"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab/vendor_mocks"
+	mockkubectl "github.com/projectriff/cnab-k8s-installer-base/pkg/kubectl/mocks"
+	"github.com/stretchr/testify/mock"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/testing"
+)
+
+var _ = Describe("Upgrade Tests", func() {
+
+	var (
+		client         *kab.Client
+		mockKubeClient *vendor_mocks.Interface
+		mockCore       *vendor_mocks.CoreV1Interface
+		mockNamespace  *vendor_mocks.NamespaceInterface
+		fakeKabClient  *fake.Clientset
+		mockKubectl    *mockkubectl.KubeCtl
+		manifest       *v1alpha1.Manifest
+		content        []byte
+		err            error
+	)
+
+	BeforeEach(func() {
+		mockKubeClient = new(vendor_mocks.Interface)
+		mockCore = new(vendor_mocks.CoreV1Interface)
+		mockNamespace = new(vendor_mocks.NamespaceInterface)
+		fakeKabClient = fake.NewSimpleClientset()
+		mockKubectl = new(mockkubectl.KubeCtl)
+		mockKubeClient.On("CoreV1").Return(mockCore)
+		mockCore.On("Namespaces").Return(mockNamespace)
+		mockNamespace.On("List", mock.Anything).Return(&v12.NamespaceList{
+			Items: []v12.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "default"}}},
+		}, nil)
+
+		content = []byte("some content")
+		manifest = &v1alpha1.Manifest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myInstall",
+			},
+			Spec: v1alpha1.KabSpec{
+				Resources: []v1alpha1.KabResource{
+					{
+						Name:    "res1",
+						Content: string(content),
+					},
+				},
+			},
+		}
+
+		client = kab.NewKnbClient(mockKubeClient, nil, fakeKabClient, nil, mockKubectl)
+	})
+
+	Context("when the bundle is not installed", func() {
+		It("an error is returned", func() {
+			fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				return true, nil, errors.NewNotFound(schema.GroupResource{}, "myInstall")
+			})
+			err = client.Upgrade(manifest)
+			Expect(err).To(MatchError("unable to lookup manifest: could not find manifest for installation name: myInstall"))
+			Expect(mockKubectl.Calls).To(BeEmpty())
+		})
+	})
+
+	Context("when the bundle is installed", func() {
+		It("the resources are applied and the manifest is updated", func() {
+			old := manifest.DeepCopy()
+			old.ResourceVersion = "42"
+			fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				return true, old, nil
+			})
+			var updated *v1alpha1.Manifest
+			fakeKabClient.PrependReactor("update", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				updated = action.(testing.UpdateAction).GetObject().(*v1alpha1.Manifest)
+				return true, updated, nil
+			})
+			mockKubectl.On("ExecStdin", []string{"apply", "-f", "-"}, &content).Return("success", nil).Once()
+
+			err = client.Upgrade(manifest)
+			Expect(err).To(BeNil())
+			mockKubectl.AssertExpectations(GinkgoT())
+			Expect(updated).ToNot(BeNil())
+			Expect(updated.ResourceVersion).To(Equal("42"))
+		})
+
+		It("the deferred resources installed on demand are upgraded", func() {
+			monitoring := []byte("monitoring content")
+			manifest.Spec.Resources = append(manifest.Spec.Resources,
+				v1alpha1.KabResource{Name: "monitoring", Content: string(monitoring), Deferred: true},
+				v1alpha1.KabResource{Name: "tracing", Content: "tracing content", Deferred: true},
+			)
+			old := manifest.DeepCopy()
+			old.Status.InstalledDeferred = []string{"monitoring", "logging"}
+			fakeKabClient.PrependReactor("get", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				return true, old, nil
+			})
+			var updated *v1alpha1.Manifest
+			fakeKabClient.PrependReactor("update", "*", func(action testing.Action) (handled bool, ret runtime.Object, err error) {
+				updated = action.(testing.UpdateAction).GetObject().(*v1alpha1.Manifest)
+				return true, updated, nil
+			})
+			mockKubectl.On("ExecStdin", []string{"apply", "-f", "-"}, &content).Return("success", nil).Once()
+			mockKubectl.On("ExecStdin", []string{"apply", "-f", "-"}, &monitoring).Return("success", nil).Once()
+
+			err = client.Upgrade(manifest)
+			Expect(err).To(BeNil())
+			mockKubectl.AssertExpectations(GinkgoT())
+			Expect(mockKubectl.Calls).To(HaveLen(2))
+			Expect(updated.Status.InstalledDeferred).To(Equal([]string{"monitoring"}))
+		})
+	})
+})
@@ -0,0 +1,248 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// checkKinds are the kinds supported by the readiness checks of the resources
+var checkKinds = map[string]bool{"POD": true}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// ValidationProblem is a problem of a resource of the manifest, located by the Field of the
+// resource, or by the Document and Line of its content, both counted from 1. Problems of the
+// namespaces of the manifest have no Resource.
+type ValidationProblem struct {
+	Resource string
+	Field    string
+	Document int
+	Line     int
+	Message  string
+}
+
+func (p ValidationProblem) String() string {
+	if p.Resource == "" {
+		return fmt.Sprintf("%s: %s", p.Field, p.Message)
+	}
+	if p.Document > 0 {
+		return fmt.Sprintf("resource %s, document %d, line %d: %s", p.Resource, p.Document, p.Line, p.Message)
+	}
+	return fmt.Sprintf("resource %s, %s: %s", p.Resource, p.Field, p.Message)
+}
+
+// Validate reads the content of every resource of the manifest and returns all the problems found,
+// rather than stopping at the first one: duplicate or missing names, resources without content,
+// invalid labels and checks, invalid namespaces, and documents which are not kubernetes objects.
+// The content of the resources of the manifest is not changed.
+func (c *Client) Validate(manifest *v1alpha1.Manifest) []ValidationProblem {
+	problems := []ValidationProblem{}
+	names := map[string]bool{}
+	resolvers := c.resolvers()
+
+	for i, namespace := range manifest.Spec.Namespaces {
+		field := fmt.Sprintf("spec.namespaces[%d]", i)
+		report := func(field string, format string, args ...interface{}) {
+			problems = append(problems, ValidationProblem{Field: field, Message: fmt.Sprintf(format, args...)})
+		}
+
+		for _, msg := range validation.IsDNS1123Label(namespace.Name) {
+			report(field+".name", "invalid namespace name %q: %s", namespace.Name, msg)
+		}
+		if names[namespace.Name] {
+			report(field+".name", "the namespace is declared twice")
+		}
+		names[namespace.Name] = true
+		validateLabels(namespace.Labels, field+".labels", report)
+		for key := range namespace.Annotations {
+			for _, msg := range validation.IsQualifiedName(key) {
+				report(field+".annotations", "invalid annotation key %q: %s", key, msg)
+			}
+		}
+	}
+
+	names = map[string]bool{}
+	for i, resource := range manifest.Spec.Resources {
+		field := fmt.Sprintf("spec.resources[%d]", i)
+		report := func(field string, format string, args ...interface{}) {
+			problems = append(problems, ValidationProblem{Resource: resource.Name, Field: field, Message: fmt.Sprintf(format, args...)})
+		}
+
+		switch {
+		case resource.Name == "":
+			report(field+".name", "a name is required")
+		case names[resource.Name]:
+			report(field+".name", "the name is used by another resource")
+		}
+		names[resource.Name] = true
+
+		validateLabels(resource.Labels, field+".labels", report)
+
+		for j, check := range resource.Checks {
+			checkField := fmt.Sprintf("%s.checks[%d]", field, j)
+			if !checkKinds[strings.ToUpper(check.Kind)] {
+				report(checkField+".kind", "unsupported kind %q, checks support Pod", check.Kind)
+			}
+			if _, err := metav1.LabelSelectorAsSelector(&check.Selector); err != nil {
+				report(checkField+".selector", "invalid selector: %v", err)
+			}
+			if check.JsonPath != "" {
+				if err := jsonpath.New(checkField).Parse(jsonpathTemplate(check.JsonPath)); err != nil {
+					report(checkField+".jsonpath", "invalid jsonpath %q: %v", check.JsonPath, err)
+				}
+			}
+		}
+
+		if resource.Content == "" && resource.Path == "" && resource.Chart == nil && resource.Kustomize == "" {
+			report(field, "a content, path, chart or kustomization is required")
+			continue
+		}
+		if u, err := url.Parse(resource.Path); err == nil && u.Scheme != "" && resolvers[u.Scheme] == nil {
+			log.Debugf("skipping the content of resource %s, %s paths cannot be read without a cluster", resource.Name, u.Scheme)
+			continue
+		}
+
+		// inline a copy of the resource alone, so that every resource is read
+		single := &v1alpha1.Manifest{Spec: v1alpha1.KabSpec{Resources: []v1alpha1.KabResource{*resource.DeepCopy()}}}
+		err := single.InlineContentWith(resolvers)
+		if err != nil {
+			report(field, "cannot read the content: %v", err)
+			continue
+		}
+		problems = append(problems, validateContent(resource.Name, single.Spec.Resources[0].Content)...)
+	}
+	return problems
+}
+
+func validateLabels(labels map[string]string, field string, report func(field string, format string, args ...interface{})) {
+	for key, value := range labels {
+		for _, msg := range validation.IsQualifiedName(key) {
+			report(field, "invalid label key %q: %s", key, msg)
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			report(field, "invalid value %q of label %s: %s", value, key, msg)
+		}
+	}
+}
+
+// validateContent checks that every document of the content is a kubernetes object with an
+// apiVersion, a kind and a name
+func validateContent(resource string, content string) []ValidationProblem {
+	problems := []ValidationProblem{}
+	for _, doc := range splitDocumentLines(content) {
+		report := func(line int, format string, args ...interface{}) {
+			problems = append(problems, ValidationProblem{Resource: resource, Document: doc.number, Line: line, Message: fmt.Sprintf(format, args...)})
+		}
+
+		obj := map[string]interface{}{}
+		err := yaml.Unmarshal([]byte(doc.content), &obj)
+		if err != nil {
+			// yaml reports lines relative to the document
+			line := doc.firstLine
+			if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+				n, _ := strconv.Atoi(match[1])
+				line = doc.startLine + n - 1
+			}
+			report(line, "invalid yaml: %v", err)
+			continue
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		u := unstructured.Unstructured{Object: obj}
+		if u.GetAPIVersion() == "" {
+			report(doc.firstLine, "apiVersion is required")
+		}
+		if u.GetKind() == "" {
+			report(doc.firstLine, "kind is required")
+		}
+		if u.GetName() == "" && u.GetGenerateName() == "" && !strings.HasSuffix(u.GetKind(), "List") {
+			report(doc.firstLine, "metadata.name is required")
+		}
+	}
+	return problems
+}
+
+type documentLines struct {
+	number  int
+	content string
+	// startLine is the line where the document starts, firstLine its first line with yaml
+	startLine int
+	firstLine int
+}
+
+// splitDocumentLines splits a multi-document yaml at its --- separators, keeping track of the
+// lines of the documents. Documents without yaml, e.g. before a leading separator, are skipped and
+// not counted.
+func splitDocumentLines(content string) []documentLines {
+	docs := []documentLines{}
+	current := documentLines{number: 1, startLine: 1}
+	var lines []string
+	flush := func() {
+		current.content = strings.Join(lines, "\n")
+		if current.firstLine > 0 {
+			docs = append(docs, current)
+		}
+	}
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimRight(line, "\r")
+		if trimmed == "---" || strings.HasPrefix(trimmed, "--- ") {
+			if i > 0 {
+				flush()
+				if current.firstLine > 0 {
+					current = documentLines{number: current.number + 1}
+				}
+			}
+			current.startLine = i + 2
+			current.firstLine = 0
+			lines = nil
+			continue
+		}
+		lines = append(lines, line)
+		text := strings.TrimSpace(trimmed)
+		if current.firstLine == 0 && text != "" && !strings.HasPrefix(text, "#") {
+			current.firstLine = i + 1
+		}
+	}
+	flush()
+	return docs
+}
+
+// WriteValidationProblems writes one problem per line
+func WriteValidationProblems(out io.Writer, problems []ValidationProblem) error {
+	for _, problem := range problems {
+		_, err := fmt.Fprintln(out, problem.String())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kab_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/apis/kab/v1alpha1"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/kab"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Validate", func() {
+
+	var (
+		client   *kab.Client
+		manifest *v1alpha1.Manifest
+		problems []kab.ValidationProblem
+	)
+
+	BeforeEach(func() {
+		client = kab.NewKnbClient(nil, nil, nil, nil, nil)
+	})
+
+	JustBeforeEach(func() {
+		problems = client.Validate(manifest)
+	})
+
+	Context("when the manifest is valid", func() {
+		BeforeEach(func() {
+			manifest = &v1alpha1.Manifest{Spec: v1alpha1.KabSpec{Resources: []v1alpha1.KabResource{
+				{
+					Name:    "riff",
+					Content: "---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: riff-system\n---\n# comment only\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  generateName: riff-\n",
+					Labels:  map[string]string{"projectriff.io/component": "core"},
+					Checks: []v1alpha1.ResourceChecks{{
+						Kind:     "Pod",
+						Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "riff"}},
+						Pattern:  "Running",
+					}},
+				},
+				{Name: "config", Path: "configmap://riff-system/resources/config.yaml"},
+			}}}
+		})
+
+		It("reports no problem", func() {
+			Expect(problems).To(BeEmpty())
+		})
+
+		It("does not change the manifest", func() {
+			Expect(manifest.Spec.Resources[1].Content).To(BeEmpty())
+			Expect(manifest.Spec.Resources[1].Path).To(Equal("configmap://riff-system/resources/config.yaml"))
+		})
+	})
+
+	Context("when the resources have problems", func() {
+		BeforeEach(func() {
+			manifest = &v1alpha1.Manifest{Spec: v1alpha1.KabSpec{Resources: []v1alpha1.KabResource{
+				{
+					Name:    "riff",
+					Content: "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: riff-system\n---\n\nkind: Service\nmetadata:\n  name: riff\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: [riff\n",
+					Labels:  map[string]string{"-invalid": "core"},
+				},
+				{
+					Name:    "riff",
+					Content: "apiVersion: v1\nkind: Secret\n",
+					Checks: []v1alpha1.ResourceChecks{{
+						Kind: "Deployment",
+						Selector: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+							{Key: "app", Operator: "Near"},
+						}},
+						JsonPath: "{.status",
+					}, {
+						Kind:     "Pod",
+						JsonPath: ".status[",
+					}},
+				},
+				{Name: "empty"},
+				{Name: "missing", Path: "./fixtures/missing.yaml"},
+			}}}
+		})
+
+		It("reports all of them with their position", func() {
+			lines := []string{}
+			for _, problem := range problems {
+				lines = append(lines, problem.String())
+			}
+			Expect(lines).To(ConsistOf(
+				HavePrefix(`resource riff, spec.resources[0].labels: invalid label key "-invalid": name part must consist of alphanumeric characters`),
+				"resource riff, document 2, line 7: apiVersion is required",
+				"resource riff, document 3, line 14: invalid yaml: error converting YAML to JSON: yaml: line 4: did not find expected ',' or ']'",
+				"resource riff, spec.resources[1].name: the name is used by another resource",
+				`resource riff, spec.resources[1].checks[0].kind: unsupported kind "Deployment", checks support Pod`,
+				`resource riff, spec.resources[1].checks[0].selector: invalid selector: "Near" is not a valid pod selector operator`,
+				`resource riff, spec.resources[1].checks[0].jsonpath: invalid jsonpath "{.status": unclosed action`,
+				`resource riff, spec.resources[1].checks[1].jsonpath: invalid jsonpath ".status[": unterminated array`,
+				"resource riff, document 1, line 1: metadata.name is required",
+				"resource empty, spec.resources[2]: a content, path, chart or kustomization is required",
+				And(HavePrefix("resource missing, spec.resources[3]: cannot read the content: "), HaveSuffix("missing.yaml: no such file or directory")),
+			))
+		})
+
+		It("writes one problem per line", func() {
+			out := &bytes.Buffer{}
+			Expect(kab.WriteValidationProblems(out, problems[:2])).To(Succeed())
+			Expect(out.String()).To(Equal(problems[0].String() + "\n" + problems[1].String() + "\n"))
+		})
+	})
+
+	Context("when the namespaces have problems", func() {
+		BeforeEach(func() {
+			manifest = &v1alpha1.Manifest{Spec: v1alpha1.KabSpec{Namespaces: []v1alpha1.KabNamespace{
+				{Name: "riff-system", Labels: map[string]string{"istio-injection": "enabled"}},
+				{Name: "riff-system"},
+				{Name: "Riff", Annotations: map[string]string{"-owner": "riff"}},
+			}}}
+		})
+
+		It("reports them by field", func() {
+			lines := []string{}
+			for _, problem := range problems {
+				lines = append(lines, problem.String())
+			}
+			Expect(lines).To(ConsistOf(
+				"spec.namespaces[1].name: the namespace is declared twice",
+				HavePrefix(`spec.namespaces[2].name: invalid namespace name "Riff": a DNS-1123 label must consist of lower case alphanumeric characters`),
+				HavePrefix(`spec.namespaces[2].annotations: invalid annotation key "-owner": name part must consist of alphanumeric characters`),
+			))
+		})
+	})
+})
@@ -28,18 +28,24 @@ type KubeCtl interface {
 // processKubeCtl interacts with kubernetes by spawning a process and running the kubectl
 // command line tool.
 type processKubeCtl struct {
+	globalArgs []string
 }
 
 func (kc *processKubeCtl) Exec(cmdArgs []string) (string, error) {
-	out, err := Exec("kubectl", cmdArgs, 60*time.Second)
+	out, err := Exec("kubectl", kc.args(cmdArgs), 60*time.Second)
 	return string(out), err
 }
 
 func (kc *processKubeCtl) ExecStdin(cmdArgs []string, stdin *[]byte) (string, error) {
-	out, err := ExecStdin("kubectl", cmdArgs, stdin, 60*time.Second)
+	out, err := ExecStdin("kubectl", kc.args(cmdArgs), stdin, 60*time.Second)
 	return string(out), err
 }
 
-func RealKubeCtl() KubeCtl {
-	return &processKubeCtl{}
+func (kc *processKubeCtl) args(cmdArgs []string) []string {
+	return append(append([]string{}, kc.globalArgs...), cmdArgs...)
+}
+
+// RealKubeCtl creates a KubeCtl passing the given global flags, like --kubeconfig, to every command
+func RealKubeCtl(globalArgs ...string) KubeCtl {
+	return &processKubeCtl{globalArgs: globalArgs}
 }
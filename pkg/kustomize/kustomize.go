@@ -62,7 +62,13 @@ func (kust *kustomizer) ApplyLabels(resourceDefinition string, labels map[string
 	if err != nil {
 		return nil, err
 	}
-	return kust.runBuild()
+	return runBuild(kust.fs, kust.fakeDir)
+}
+
+// Build builds the kustomization in dir, which may refer to bases and resources elsewhere on the
+// file system, and returns the resulting objects
+func Build(dir string) ([]byte, error) {
+	return runBuild(fs.MakeRealFS(), dir)
 }
 
 func (kust *kustomizer) writeResourceFile(resourceContents []byte) (string, error) {
@@ -88,11 +94,11 @@ resources:
 	return nil
 }
 
-func (kust *kustomizer) runBuild() ([]byte, error) {
+func runBuild(fSys fs.FileSystem, dir string) ([]byte, error) {
 	var out bytes.Buffer
 	kustomizeFactory := k8sdeps.NewFactory()
-	kustomizeBuildCommand := build.NewCmdBuild(&out, kust.fs, kustomizeFactory.ResmapF, kustomizeFactory.TransformerF)
-	kustomizeBuildCommand.SetArgs([]string{kust.fakeDir})
+	kustomizeBuildCommand := build.NewCmdBuild(&out, fSys, kustomizeFactory.ResmapF, kustomizeFactory.TransformerF)
+	kustomizeBuildCommand.SetArgs([]string{dir})
 	kustomizeBuildCommand.SetOutput(ioutil.Discard)
 	_, err := kustomizeBuildCommand.ExecuteC()
 	if err != nil {
@@ -83,3 +83,19 @@ spec:
 		Expect(string(result)).To(Equal(expectedResourceContent))
 	})
 })
+
+var _ = Describe("Build", func() {
+
+	It("builds an overlay of a base", func() {
+		result, err := kustomize.Build("./fixtures/overlays/prod")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result)).To(ContainSubstring("name: prod-controller"))
+		Expect(string(result)).To(ContainSubstring("namespace: riff-system"))
+		Expect(string(result)).To(ContainSubstring("replicas: 3"))
+	})
+
+	It("fails when the directory is not a kustomization", func() {
+		_, err := kustomize.Build("./fixtures")
+		Expect(err).To(HaveOccurred())
+	})
+})
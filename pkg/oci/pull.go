@@ -0,0 +1,256 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package oci
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+
+	HTTP_TIMEOUT = 60 * time.Second
+)
+
+var bearerParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// Reference is an artifact in a registry, given as oci://registry/repository:tag or
+// oci://registry/repository@digest
+type Reference struct {
+	Registry   string
+	Repository string
+	// Reference is the tag or digest of the artifact
+	Reference string
+}
+
+type manifest struct {
+	Layers []descriptor `json:"layers"`
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+// ParseReference reads an oci:// url
+func ParseReference(path string) (Reference, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return Reference{}, err
+	}
+	if u.Scheme != "oci" || u.Host == "" {
+		return Reference{}, fmt.Errorf("invalid oci reference %s, expected oci://registry/repository:tag", path)
+	}
+	repository := strings.TrimPrefix(u.Path, "/")
+	ref := "latest"
+	if i := strings.Index(repository, "@"); i >= 0 {
+		repository, ref = repository[:i], repository[i+1:]
+	} else if i := strings.LastIndex(repository, ":"); i >= 0 {
+		repository, ref = repository[:i], repository[i+1:]
+	}
+	if repository == "" || ref == "" {
+		return Reference{}, fmt.Errorf("invalid oci reference %s, expected oci://registry/repository:tag", path)
+	}
+	return Reference{Registry: u.Host, Repository: repository, Reference: ref}, nil
+}
+
+// Pull reads the artifact at an oci:// url. The content of its layers is returned, decompressed
+// when the media type is gzip, and separated as yaml documents when there are several layers. Every
+// blob is verified against its digest. Registries are accessed anonymously, with https except for
+// localhost.
+func Pull(path string) ([]byte, error) {
+	ref, err := ParseReference(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &client{http: &http.Client{Timeout: HTTP_TIMEOUT}, ref: ref}
+	log.Debugf("pulling %s", path)
+
+	manifestBytes, err := c.get("manifests/"+ref.Reference, ociManifestMediaType+", "+dockerManifestMediaType)
+	if err != nil {
+		return nil, fmt.Errorf("error pulling manifest of %s: %v", path, err)
+	}
+	if strings.HasPrefix(ref.Reference, "sha256:") {
+		err = verify(ref.Reference, manifestBytes)
+		if err != nil {
+			return nil, fmt.Errorf("manifest of %s: %v", path, err)
+		}
+	}
+	var m manifest
+	err = json.Unmarshal(manifestBytes, &m)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing manifest of %s: %v", path, err)
+	}
+	if len(m.Layers) == 0 {
+		return nil, fmt.Errorf("artifact %s has no layers", path)
+	}
+
+	var content bytes.Buffer
+	for i, layer := range m.Layers {
+		blob, err := c.get("blobs/"+layer.Digest, "*/*")
+		if err != nil {
+			return nil, fmt.Errorf("error pulling layer %s of %s: %v", layer.Digest, path, err)
+		}
+		err = verify(layer.Digest, blob)
+		if err != nil {
+			return nil, fmt.Errorf("layer of %s: %v", path, err)
+		}
+		if strings.HasSuffix(layer.MediaType, "gzip") {
+			blob, err = gunzip(blob)
+			if err != nil {
+				return nil, fmt.Errorf("error decompressing layer %s of %s: %v", layer.Digest, path, err)
+			}
+		}
+		if i > 0 {
+			content.WriteString("\n---\n")
+		}
+		content.Write(blob)
+	}
+	return content.Bytes(), nil
+}
+
+type client struct {
+	http  *http.Client
+	ref   Reference
+	token string
+}
+
+func (c *client) get(resource string, accept string) ([]byte, error) {
+	res, err := c.do(resource, accept)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusUnauthorized && c.token == "" {
+		challenge := res.Header.Get("WWW-Authenticate")
+		res.Body.Close()
+		c.token, err = c.anonymousToken(challenge)
+		if err != nil {
+			return nil, err
+		}
+		res, err = c.do(resource, accept)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned %s", res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+func (c *client) do(resource string, accept string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s://%s/v2/%s/%s", scheme(c.ref.Registry), c.ref.Registry, c.ref.Repository, resource), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.http.Do(req)
+}
+
+// anonymousToken requests a token for pulling from the realm of a bearer challenge
+func (c *client) anonymousToken(challenge string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", errors.New("registry requires authentication")
+	}
+	params := map[string]string{}
+	for _, match := range bearerParam.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(match[1])] = match[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid authentication challenge %q", challenge)
+	}
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull", c.ref.Repository))
+	realm.RawQuery = query.Encode()
+
+	res, err := c.http.Get(realm.String())
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error requesting a token: %s", res.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&token)
+	if err != nil {
+		return "", err
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+func scheme(registry string) string {
+	host, _, err := net.SplitHostPort(registry)
+	if err != nil {
+		host = registry
+	}
+	if host == "localhost" || host == "127.0.0.1" || host == "::1" {
+		return "http"
+	}
+	return "https"
+}
+
+func verify(digest string, content []byte) error {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return fmt.Errorf("unsupported digest %s", digest)
+	}
+	sum := sha256.Sum256(content)
+	actual := "sha256:" + hex.EncodeToString(sum[:])
+	if actual != digest {
+		return fmt.Errorf("digest mismatch: expected %s, got %s", digest, actual)
+	}
+	return nil
+}
+
+func gunzip(content []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
@@ -0,0 +1,211 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package oci_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/oci"
+)
+
+// registry is an in-process registry serving the artifacts of a single repository
+type registry struct {
+	manifests   map[string][]byte
+	blobs       map[string][]byte
+	requireAuth bool
+}
+
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		Expect(req.URL.Query().Get("scope")).To(Equal("repository:projectriff/riff:pull"))
+		w.Write([]byte(`{"token":"anonymous"}`))
+		return
+	}
+	if r.requireAuth && req.Header.Get("Authorization") != "Bearer anonymous" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="test"`, req.Host))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	const prefix = "/v2/projectriff/riff/"
+	switch {
+	case strings.HasPrefix(req.URL.Path, prefix+"manifests/"):
+		content, ok := r.manifests[strings.TrimPrefix(req.URL.Path, prefix+"manifests/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		w.Write(content)
+	case strings.HasPrefix(req.URL.Path, prefix+"blobs/"):
+		content, ok := r.blobs[strings.TrimPrefix(req.URL.Path, prefix+"blobs/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(content)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (r *registry) push(tag string, mediaType string, layers ...[]byte) string {
+	descriptors := []map[string]string{}
+	for _, layer := range layers {
+		d := digest(layer)
+		r.blobs[d] = layer
+		descriptors = append(descriptors, map[string]string{"mediaType": mediaType, "digest": d})
+	}
+	manifest, err := json.Marshal(map[string]interface{}{"schemaVersion": 2, "layers": descriptors})
+	Expect(err).NotTo(HaveOccurred())
+	r.manifests[tag] = manifest
+	r.manifests[digest(manifest)] = manifest
+	return digest(manifest)
+}
+
+func digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+var _ = Describe("Pull", func() {
+
+	var (
+		reg     *registry
+		server  *httptest.Server
+		content []byte
+		err     error
+	)
+
+	BeforeEach(func() {
+		reg = &registry{manifests: map[string][]byte{}, blobs: map[string][]byte{}}
+		server = httptest.NewServer(reg)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	host := func() string {
+		return strings.TrimPrefix(server.URL, "http://")
+	}
+
+	Context("when the artifact has a single layer", func() {
+		BeforeEach(func() {
+			reg.push("0.1.0", "application/vnd.projectriff.resource.v1+yaml", []byte("kind: ConfigMap\n"))
+			content, err = oci.Pull(fmt.Sprintf("oci://%s/projectriff/riff:0.1.0", host()))
+		})
+
+		It("returns the content of the layer", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("kind: ConfigMap\n"))
+		})
+	})
+
+	Context("when the artifact has several gzipped layers", func() {
+		BeforeEach(func() {
+			d := reg.push("0.1.0", "application/vnd.oci.image.layer.v1.tar+gzip", gzipped("kind: ConfigMap"), gzipped("kind: Secret"))
+			content, err = oci.Pull(fmt.Sprintf("oci://%s/projectriff/riff@%s", host(), d))
+		})
+
+		It("returns the decompressed layers as yaml documents", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("kind: ConfigMap\n---\nkind: Secret"))
+		})
+	})
+
+	Context("when the registry requires an anonymous token", func() {
+		BeforeEach(func() {
+			reg.requireAuth = true
+			reg.push("latest", "application/vnd.projectriff.resource.v1+yaml", []byte("kind: ConfigMap\n"))
+			content, err = oci.Pull(fmt.Sprintf("oci://%s/projectriff/riff", host()))
+		})
+
+		It("pulls the artifact with the token", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("kind: ConfigMap\n"))
+		})
+	})
+
+	Context("when a blob does not match its digest", func() {
+		BeforeEach(func() {
+			reg.push("0.1.0", "application/vnd.projectriff.resource.v1+yaml", []byte("kind: ConfigMap\n"))
+			for d := range reg.blobs {
+				reg.blobs[d] = []byte("kind: Secret\n")
+			}
+			content, err = oci.Pull(fmt.Sprintf("oci://%s/projectriff/riff:0.1.0", host()))
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("digest mismatch")))
+		})
+	})
+
+	Context("when the artifact does not exist", func() {
+		BeforeEach(func() {
+			content, err = oci.Pull(fmt.Sprintf("oci://%s/projectriff/riff:0.1.0", host()))
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("404")))
+		})
+	})
+})
+
+var _ = Describe("ParseReference", func() {
+
+	It("parses a tag", func() {
+		ref, err := oci.ParseReference("oci://registry.example.com:5000/projectriff/riff:0.1.0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref).To(Equal(oci.Reference{Registry: "registry.example.com:5000", Repository: "projectriff/riff", Reference: "0.1.0"}))
+	})
+
+	It("parses a digest", func() {
+		ref, err := oci.ParseReference("oci://gcr.io/projectriff/riff@sha256:abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref.Reference).To(Equal("sha256:abc"))
+	})
+
+	It("defaults to the latest tag", func() {
+		ref, err := oci.ParseReference("oci://gcr.io/projectriff/riff")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref.Reference).To(Equal("latest"))
+	})
+
+	It("rejects a reference without repository", func() {
+		_, err := oci.ParseReference("oci://gcr.io")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+func gzipped(content string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(content))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(w.Close()).To(Succeed())
+	return buf.Bytes()
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scan
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pivotal/go-ape/pkg/furl"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// ImagePath locates images outside of pod specs, in the objects of a kind such as a custom resource
+type ImagePath struct {
+	Kind     string
+	JsonPath string
+}
+
+// ParseImagePath reads an ImagePath given as kind=jsonpath
+func ParseImagePath(value string) (ImagePath, error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return ImagePath{}, fmt.Errorf("invalid image path %q, expected kind=jsonpath", value)
+	}
+	return ImagePath{Kind: parts[0], JsonPath: parts[1]}, nil
+}
+
+func ListImages(res string, baseDir string, paths ...ImagePath) ([]string, error) {
+	log.Debugf("Scanning %s", res)
+	contents, err := furl.Read(res, baseDir)
+	if err != nil {
+		return nil, err
+	}
+	return ListImagesFromContent(contents, paths...)
+}
+
+// ListImagesFromContent returns the unique, sorted images of the containers and init containers of
+// the objects in a multi-document yaml, wherever a pod spec is nested in the object, and the images
+// found at the paths for the kind of the object
+func ListImagesFromContent(contents []byte, paths ...ImagePath) ([]string, error) {
+	objects, err := ListObjectsFromContent(contents)
+	if err != nil {
+		return nil, err
+	}
+	images := map[string]bool{}
+	for _, obj := range objects {
+		collectContainerImages(obj.Object, images)
+		for _, path := range paths {
+			if !strings.EqualFold(path.Kind, obj.GetKind()) {
+				continue
+			}
+			err = collectPathImages(obj.Object, path, images)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return sortedKeys(images), nil
+}
+
+func collectPathImages(obj map[string]interface{}, path ImagePath, images map[string]bool) error {
+	template := path.JsonPath
+	if !strings.HasPrefix(template, "{") {
+		template = "{" + template + "}"
+	}
+	parser := jsonpath.New(path.Kind).AllowMissingKeys(true)
+	err := parser.Parse(template)
+	if err != nil {
+		return fmt.Errorf("invalid image path %s for %s: %v", path.JsonPath, path.Kind, err)
+	}
+	results, err := parser.FindResults(obj)
+	if err != nil {
+		return fmt.Errorf("error finding images at %s in %s: %v", path.JsonPath, path.Kind, err)
+	}
+	for _, result := range results {
+		for _, value := range result {
+			if image, ok := value.Interface().(string); ok && image != "" {
+				images[image] = true
+			}
+		}
+	}
+	return nil
+}
+
+func collectContainerImages(value interface{}, images map[string]bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if key == "containers" || key == "initContainers" {
+				if containers, ok := field.([]interface{}); ok {
+					for _, c := range containers {
+						container, _ := c.(map[string]interface{})
+						if image, ok := container["image"].(string); ok && image != "" {
+							images[image] = true
+						}
+					}
+				}
+			}
+			collectContainerImages(field, images)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectContainerImages(item, images)
+		}
+	}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scan_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+)
+
+var _ = Describe("ListImagesFromContent", func() {
+	var (
+		res    string
+		paths  []scan.ImagePath
+		images []string
+		err    error
+	)
+
+	BeforeEach(func() {
+		paths = nil
+	})
+
+	JustBeforeEach(func() {
+		contents, readErr := ioutil.ReadFile(filepath.Join("fixtures", res))
+		Expect(readErr).NotTo(HaveOccurred())
+		images, err = scan.ListImagesFromContent(contents, paths...)
+	})
+
+	Context("when the resources contain pod specs", func() {
+		BeforeEach(func() {
+			res = "images.yaml"
+		})
+
+		It("the unique images of the containers are returned", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(images).To(Equal([]string{
+				"busybox:1.31",
+				"gcr.io/projectriff/controller@sha256:1a2b3c",
+				"projectriff/cleanup:0.1",
+			}))
+		})
+	})
+
+	Context("when image paths are given for a custom resource", func() {
+		BeforeEach(func() {
+			res = "images.yaml"
+			paths = []scan.ImagePath{{Kind: "ClusterBuildTemplate", JsonPath: ".spec.steps[*].image"}}
+		})
+
+		It("the images at the paths are returned too", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(images).To(ContainElement("packs/cf:build"))
+			Expect(images).To(ContainElement("packs/cf:export"))
+			Expect(images).To(HaveLen(5))
+		})
+	})
+
+	Context("when an image path is invalid", func() {
+		BeforeEach(func() {
+			res = "images.yaml"
+			paths = []scan.ImagePath{{Kind: "ClusterBuildTemplate", JsonPath: ".spec.steps[*"}}
+		})
+
+		It("should return a suitable error", func() {
+			Expect(err).To(MatchError(HavePrefix("invalid image path .spec.steps[* for ClusterBuildTemplate: ")))
+		})
+	})
+
+	Context("when the resource file does not contain containers", func() {
+		BeforeEach(func() {
+			res = "simple.yaml"
+		})
+
+		It("an empty list is returned", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(images).To(BeEmpty())
+		})
+	})
+
+	Context("when the resource file contains invalid YAML", func() {
+		BeforeEach(func() {
+			res = "invalid.yaml"
+		})
+
+		It("should return a suitable error", func() {
+			Expect(err).To(MatchError(HavePrefix("error parsing content")))
+		})
+	})
+})
+
+var _ = Describe("ParseImagePath", func() {
+	It("splits the kind and jsonpath", func() {
+		path, err := scan.ParseImagePath("Image={.spec.image}")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(Equal(scan.ImagePath{Kind: "Image", JsonPath: "{.spec.image}"}))
+	})
+
+	It("rejects a value without a jsonpath", func() {
+		_, err := scan.ParseImagePath("Image")
+		Expect(err).To(MatchError("invalid image path \"Image\", expected kind=jsonpath"))
+	})
+})
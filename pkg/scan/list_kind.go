@@ -24,11 +24,12 @@ import (
 
 	"github.com/ghodss/yaml"
 	"github.com/pivotal/go-ape/pkg/furl"
+	log "github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func ListKind(res string, baseDir string) ([]string, error) {
-	fmt.Printf("Scanning %s\n", res)
+	log.Debugf("Scanning %s", res)
 	contents, err := furl.Read(res, baseDir)
 	if err != nil {
 		return nil, err
@@ -40,15 +41,7 @@ func ListKindFromContent(contents []byte) ([]string, error) {
 	var err error
 	types := map[string]bool{}
 
-	docs := strings.Split(string(contents), "---\n")
-	if runtime.GOOS == "windows" {
-		// allow lines to end in LF or CRLF since either may occur
-		d := strings.Split(string(contents), "---\r\n")
-		if len(d) > len(docs) {
-			docs = d
-		}
-	}
-	for _, doc := range docs {
+	for _, doc := range splitDocuments(contents) {
 		if strings.TrimSpace(doc) != "" {
 			tm := metav1.TypeMeta{}
 			err = yaml.Unmarshal([]byte(doc), &tm)
@@ -70,3 +63,15 @@ func ListKindFromContent(contents []byte) ([]string, error) {
 	sort.Strings(retVal) // for deterministic order in tests
 	return retVal, nil
 }
+
+func splitDocuments(contents []byte) []string {
+	docs := strings.Split(string(contents), "---\n")
+	if runtime.GOOS == "windows" {
+		// allow lines to end in LF or CRLF since either may occur
+		d := strings.Split(string(contents), "---\r\n")
+		if len(d) > len(docs) {
+			docs = d
+		}
+	}
+	return docs
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ListObjectsFromContent parses every document of a multi-document yaml into an object,
+// skipping documents which do not declare a kind
+func ListObjectsFromContent(contents []byte) ([]unstructured.Unstructured, error) {
+	objects := []unstructured.Unstructured{}
+
+	for _, doc := range splitDocuments(contents) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		obj := map[string]interface{}{}
+		err := yaml.Unmarshal([]byte(doc), &obj)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing content: %v", err)
+		}
+		u := unstructured.Unstructured{Object: obj}
+		if u.GetKind() == "" {
+			continue
+		}
+		objects = append(objects, u)
+	}
+	return objects, nil
+}
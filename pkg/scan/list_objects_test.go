@@ -0,0 +1,76 @@
+/*
+ * Copyright 2019 The original author or authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scan_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/projectriff/cnab-k8s-installer-base/pkg/scan"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("ListObjectsFromContent", func() {
+	var (
+		res     string
+		objects []unstructured.Unstructured
+		err     error
+	)
+
+	JustBeforeEach(func() {
+		contents, readErr := ioutil.ReadFile(filepath.Join("fixtures", res))
+		Expect(readErr).NotTo(HaveOccurred())
+		objects, err = scan.ListObjectsFromContent(contents)
+	})
+
+	Context("when the resource file does not contain 'kind' key", func() {
+		BeforeEach(func() {
+			res = "simple.yaml"
+		})
+
+		It("an empty list is returned", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objects).To(BeEmpty())
+		})
+	})
+
+	Context("when using a realistic resource file", func() {
+		BeforeEach(func() {
+			res = "complex.yaml"
+		})
+
+		It("should parse every object", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objects[0].GetKind()).To(Equal("Namespace"))
+			Expect(objects[0].GetName()).To(Equal("knative-build"))
+			Expect(objects[1].GetKind()).To(Equal("ClusterRole"))
+			Expect(objects[1].GetName()).To(Equal("knative-build-admin"))
+		})
+	})
+
+	Context("when the resource file contains invalid YAML", func() {
+		BeforeEach(func() {
+			res = "invalid.yaml"
+		})
+
+		It("should return a suitable error", func() {
+			Expect(err).To(MatchError(HavePrefix("error parsing content")))
+		})
+	})
+})